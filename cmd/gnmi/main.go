@@ -23,12 +23,16 @@ import (
 
 // TODO: Make this more clear
 var help = `Usage of gnmi:
-gnmi -addr [<VRF-NAME>/]ADDRESS:PORT [options...]
+gnmi -addr [<VRF-NAME>/]ADDRESS:PORT|unix:///PATH [options...]
   capabilities
   get (origin=ORIGIN) (target=TARGET) PATH+
   subscribe (origin=ORIGIN) (target=TARGET) PATH+
   ((update|replace (origin=ORIGIN) (target=TARGET) PATH JSON|FILE) |
    (delete (origin=ORIGIN) (target=TARGET) PATH))+
+
+A PATH may start with its own origin, as in eos_native:/Sysdb/... or
+openconfig:/interfaces, which takes precedence over origin=ORIGIN.
+This allows mixing OpenConfig and native paths in one request.
 `
 
 func usageAndExit(s string) {
@@ -41,7 +45,8 @@ func usageAndExit(s string) {
 
 func main() {
 	cfg := &gnmi.Config{}
-	flag.StringVar(&cfg.Addr, "addr", "", "Address of gNMI gRPC server with optional VRF name")
+	flag.StringVar(&cfg.Addr, "addr", "", "Address of gNMI gRPC server with optional VRF name, "+
+		"or unix:///path of a unix domain socket")
 	flag.StringVar(&cfg.CAFile, "cafile", "", "Path to server TLS certificate file")
 	flag.StringVar(&cfg.CertFile, "certfile", "", "Path to client TLS certificate file")
 	flag.StringVar(&cfg.KeyFile, "keyfile", "", "Path to client TLS private key file")
@@ -71,6 +76,17 @@ func main() {
 	debug := flag.String("debug", "", "Enable a debug mode:\n"+
 		"  'proto' : prints SubscribeResponses in protobuf text format\n"+
 		"  'latency' : print timing numbers to help debug latency")
+	output := flag.String("output", "text", "Output format of subscribe:\n"+
+		"  'text' : human-readable updates\n"+
+		"  'json' : a JSON object per update, delete or sync response, one per line,\n"+
+		"           with RFC 7951 style paths, typed values and timestamps in nanoseconds\n"+
+		"  'flat' : a line per update or delete with tab-separated timestamp, target,\n"+
+		"           path and JSON value, which is empty for deletes\n"+
+		"  'proto' : SubscribeResponses in protobuf binary format, each prefixed by\n"+
+		"            its size as a varint")
+	outfile := flag.String("outfile", "", "Also write the SubscribeResponses of subscribe "+
+		"to this file,\nin protobuf binary format each prefixed by its size as a varint, "+
+		"as with -output proto")
 
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, help)
@@ -160,24 +176,46 @@ func main() {
 			g.Go(func() error {
 				return gnmi.SubscribeErr(ctx, client, subscribeOptions, respChan)
 			})
+			var handle func(*pb.SubscribeResponse) error
 			switch *debug {
 			case "proto":
-				for resp := range respChan {
+				handle = func(resp *pb.SubscribeResponse) error {
 					fmt.Println(resp)
+					return nil
 				}
 			case "latency":
-				for resp := range respChan {
+				handle = func(resp *pb.SubscribeResponse) error {
 					printLatencyStats(resp)
+					return nil
 				}
 			case "":
-				for resp := range respChan {
-					if err := gnmi.LogSubscribeResponse(resp); err != nil {
-						glog.Fatal(err)
-					}
+				if *output == "text" {
+					handle = gnmi.LogSubscribeResponse
+				} else if handle, err = newOutput(*output, os.Stdout); err != nil {
+					usageAndExit(fmt.Sprintf("error: %s", err))
 				}
 			default:
 				usageAndExit(fmt.Sprintf("unknown debug option: %q", *debug))
 			}
+			if *outfile != "" {
+				f, err := os.Create(*outfile)
+				if err != nil {
+					glog.Fatal(err)
+				}
+				defer f.Close()
+				display := handle
+				handle = func(resp *pb.SubscribeResponse) error {
+					if err := gnmi.WriteDelimited(f, resp); err != nil {
+						return fmt.Errorf("failed to write to %s: %s", *outfile, err)
+					}
+					return display(resp)
+				}
+			}
+			for resp := range respChan {
+				if err := handle(resp); err != nil {
+					glog.Fatal(err)
+				}
+			}
 			if err := g.Wait(); err != nil {
 				glog.Fatal(err)
 			}
@@ -0,0 +1,234 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aristanetworks/goarista/gnmi"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// record is the representation of an update, a delete or a sync
+// response in the json and flat output formats.
+type record struct {
+	// Timestamp is in nanoseconds since the epoch
+	Timestamp    int64       `json:"timestamp,omitempty"`
+	Target       string      `json:"target,omitempty"`
+	Origin       string      `json:"origin,omitempty"`
+	Path         string      `json:"path,omitempty"`
+	Type         string      `json:"type,omitempty"`
+	Value        interface{} `json:"value,omitempty"`
+	Delete       bool        `json:"delete,omitempty"`
+	SyncResponse bool        `json:"sync_response,omitempty"`
+}
+
+// newOutput returns a function writing the SubscribeResponses to w in
+// format, which is one of:
+//   json:  a JSON object per update, delete or sync response, one per line
+//   flat:  a line per update or delete with the timestamp, target, path and
+//          value, as in json, separated by tabs. The value of deletes is empty.
+//   proto: the SubscribeResponses in the protobuf binary format, each
+//          prefixed by its size as a varint
+func newOutput(format string, w io.Writer) (func(*pb.SubscribeResponse) error, error) {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return func(resp *pb.SubscribeResponse) error {
+			recs, err := records(resp)
+			if err != nil {
+				return err
+			}
+			for _, rec := range recs {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case "flat":
+		return func(resp *pb.SubscribeResponse) error {
+			recs, err := records(resp)
+			if err != nil {
+				return err
+			}
+			var b strings.Builder
+			for _, rec := range recs {
+				if rec.SyncResponse {
+					continue
+				}
+				var val []byte
+				if !rec.Delete {
+					if val, err = json.Marshal(rec.Value); err != nil {
+						return err
+					}
+				}
+				fmt.Fprintf(&b, "%d\t%s\t%s\t%s\n", rec.Timestamp, rec.Target, rec.Path, val)
+			}
+			_, err = io.WriteString(w, b.String())
+			return err
+		}, nil
+	case "proto":
+		return func(resp *pb.SubscribeResponse) error {
+			return gnmi.WriteDelimited(w, resp)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// records returns the records of the updates and deletes of resp,
+// or of its sync response.
+func records(resp *pb.SubscribeResponse) ([]record, error) {
+	switch r := resp.Response.(type) {
+	case *pb.SubscribeResponse_Error:
+		return nil, errors.New(r.Error.Message)
+	case *pb.SubscribeResponse_SyncResponse:
+		if !r.SyncResponse {
+			return nil, errors.New("initial sync failed")
+		}
+		return []record{{SyncResponse: true}}, nil
+	case *pb.SubscribeResponse_Update:
+		notif := r.Update
+		prefix := notif.Prefix
+		if prefix == nil {
+			prefix = &pb.Path{}
+		}
+		recs := make([]record, 0, len(notif.Delete)+len(notif.Update))
+		newRecord := func(path *pb.Path) record {
+			origin := path.GetOrigin()
+			if origin == "" {
+				origin = prefix.Origin
+			}
+			return record{
+				Timestamp: notif.Timestamp,
+				Target:    prefix.Target,
+				Origin:    origin,
+				Path:      instanceIdentifier(gnmi.JoinPaths(prefix, path)),
+			}
+		}
+		for _, del := range notif.Delete {
+			rec := newRecord(del)
+			rec.Delete = true
+			recs = append(recs, rec)
+		}
+		for _, update := range notif.Update {
+			rec := newRecord(update.Path)
+			rec.Type, rec.Value = jsonValue(update.Val)
+			recs = append(recs, rec)
+		}
+		return recs, nil
+	}
+	return nil, nil
+}
+
+// instanceIdentifier returns path in the style of the instance-identifiers
+// of RFC 7951, section 6.11, e.g. /interfaces/interface[name='Ethernet1'].
+func instanceIdentifier(path *pb.Path) string {
+	if len(path.GetElem()) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, elem := range path.Elem {
+		b.WriteByte('/')
+		b.WriteString(elem.Name)
+		keys := make([]string, 0, len(elem.Key))
+		for k := range elem.Key {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := elem.Key[k]
+			// Values are quoted with ' unless they contain one
+			quote := "'"
+			if strings.Contains(v, "'") {
+				quote = `"`
+			}
+			b.WriteString("[" + k + "=" + quote + v + quote + "]")
+		}
+	}
+	return b.String()
+}
+
+// jsonValue returns the type of val and its JSON representation,
+// following RFC 7951: 64-bit integers and decimal numbers are
+// strings, and bytes are encoded in base64.
+func jsonValue(val *pb.TypedValue) (string, interface{}) {
+	switch v := val.GetValue().(type) {
+	case *pb.TypedValue_StringVal:
+		return "string", v.StringVal
+	case *pb.TypedValue_AsciiVal:
+		return "ascii", v.AsciiVal
+	case *pb.TypedValue_IntVal:
+		return "int", strconv.FormatInt(v.IntVal, 10)
+	case *pb.TypedValue_UintVal:
+		return "uint", strconv.FormatUint(v.UintVal, 10)
+	case *pb.TypedValue_BoolVal:
+		return "bool", v.BoolVal
+	case *pb.TypedValue_FloatVal:
+		f := float64(v.FloatVal)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// Not representable as a JSON number
+			return "float", strconv.FormatFloat(f, 'g', -1, 32)
+		}
+		return "float", f
+	case *pb.TypedValue_DecimalVal:
+		return "decimal64", formatDecimal64(v.DecimalVal)
+	case *pb.TypedValue_BytesVal:
+		return "bytes", v.BytesVal
+	case *pb.TypedValue_ProtoBytes:
+		return "proto_bytes", v.ProtoBytes
+	case *pb.TypedValue_JsonVal:
+		return "json", rawJSON(v.JsonVal)
+	case *pb.TypedValue_JsonIetfVal:
+		return "json_ietf", rawJSON(v.JsonIetfVal)
+	case *pb.TypedValue_LeaflistVal:
+		elems := make([]interface{}, len(v.LeaflistVal.GetElement()))
+		for i, elem := range v.LeaflistVal.GetElement() {
+			_, elems[i] = jsonValue(elem)
+		}
+		return "leaflist", elems
+	case *pb.TypedValue_AnyVal:
+		return "any", v.AnyVal.String()
+	}
+	return "", nil
+}
+
+// rawJSON returns b as is if it's valid JSON, or as a string otherwise.
+func rawJSON(b []byte) interface{} {
+	if json.Valid(b) {
+		return json.RawMessage(b)
+	}
+	return string(b)
+}
+
+// formatDecimal64 returns the decimal representation of d, e.g. 1.05 for
+// 105 digits with a precision of 2.
+func formatDecimal64(d *pb.Decimal64) string {
+	neg := d.Digits < 0
+	abs := uint64(d.Digits)
+	if neg {
+		abs = -abs
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if p := int(d.Precision); p > 0 {
+		if len(digits) <= p {
+			digits = strings.Repeat("0", p-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-p] + "." + digits[len(digits)-p:]
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/aristanetworks/goarista/gnmi"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var testResponse = &pb.SubscribeResponse{Response: &pb.SubscribeResponse_Update{
+	Update: &pb.Notification{
+		Timestamp: 1600000000000000042,
+		Prefix: &pb.Path{
+			Origin: "openconfig",
+			Target: "dev1",
+			Elem: []*pb.PathElem{
+				{Name: "interfaces"},
+				{Name: "interface", Key: map[string]string{"name": "Ethernet1"}},
+			},
+		},
+		Delete: []*pb.Path{{Elem: []*pb.PathElem{{Name: "config"}}}},
+		Update: []*pb.Update{{
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "state"}, {Name: "counters"},
+				{Name: "in-octets"}}},
+			Val: &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}},
+		}, {
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "state"}, {Name: "description"}}},
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "it's up"}},
+		}, {
+			Path: &pb.Path{Elem: []*pb.PathElem{{Name: "state"}, {Name: "config"}}},
+			Val: &pb.TypedValue{
+				Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"mtu": 1500}`)}},
+		}},
+	},
+}}
+
+func TestOutput(t *testing.T) {
+	syncResponse := &pb.SubscribeResponse{
+		Response: &pb.SubscribeResponse_SyncResponse{SyncResponse: true}}
+	for name, tc := range map[string]struct {
+		format   string
+		expected string
+	}{
+		"json": {
+			format: "json",
+			expected: `{"timestamp":1600000000000000042,"target":"dev1","origin":"openconfig",` +
+				`"path":"/interfaces/interface[name='Ethernet1']/config","delete":true}
+{"timestamp":1600000000000000042,"target":"dev1","origin":"openconfig",` +
+				`"path":"/interfaces/interface[name='Ethernet1']/state/counters/in-octets",` +
+				`"type":"uint","value":"42"}
+{"timestamp":1600000000000000042,"target":"dev1","origin":"openconfig",` +
+				`"path":"/interfaces/interface[name='Ethernet1']/state/description",` +
+				`"type":"string","value":"it's up"}
+{"timestamp":1600000000000000042,"target":"dev1","origin":"openconfig",` +
+				`"path":"/interfaces/interface[name='Ethernet1']/state/config",` +
+				`"type":"json_ietf","value":{"mtu":1500}}
+{"sync_response":true}
+`,
+		},
+		"flat": {
+			format: "flat",
+			expected: "1600000000000000042\tdev1\t" +
+				"/interfaces/interface[name='Ethernet1']/config\t\n" +
+				"1600000000000000042\tdev1\t" +
+				"/interfaces/interface[name='Ethernet1']/state/counters/in-octets\t\"42\"\n" +
+				"1600000000000000042\tdev1\t" +
+				"/interfaces/interface[name='Ethernet1']/state/description\t\"it's up\"\n" +
+				"1600000000000000042\tdev1\t" +
+				"/interfaces/interface[name='Ethernet1']/state/config\t{\"mtu\":1500}\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			write, err := newOutput(tc.format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, resp := range []*pb.SubscribeResponse{testResponse, syncResponse} {
+				if err := write(resp); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestOutputProto(t *testing.T) {
+	var buf bytes.Buffer
+	write, err := newOutput("proto", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := write(testResponse); err != nil {
+		t.Fatal(err)
+	}
+	var resp pb.SubscribeResponse
+	if err := gnmi.ReadDelimited(bufio.NewReader(&buf), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(testResponse, &resp) {
+		t.Errorf("expected %v, got %v", testResponse, &resp)
+	}
+
+	if _, err := newOutput("xml", &buf); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestFormatDecimal64(t *testing.T) {
+	for _, tc := range []struct {
+		digits    int64
+		precision uint32
+		expected  string
+	}{
+		{105, 2, "1.05"},
+		{-5, 1, "-0.5"},
+		{42, 0, "42"},
+		{7, 3, "0.007"},
+		{-1234, 2, "-12.34"},
+	} {
+		d := &pb.Decimal64{Digits: tc.digits, Precision: tc.precision}
+		if s := formatDecimal64(d); s != tc.expected {
+			t.Errorf("expected %s for %v, got %s", tc.expected, d, s)
+		}
+	}
+}
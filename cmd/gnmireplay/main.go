@@ -0,0 +1,194 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// The gnmireplay tool replays a file of SubscribeResponses recorded with
+// the gnmi command, to a gNMIReverse collector or to the subscribers of
+// a fake gNMI target, to test collectors without live devices.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aristanetworks/goarista/gnmireverse"
+
+	"github.com/aristanetworks/glog"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	modeCollector = "collector"
+	modeTarget    = "target"
+)
+
+func main() {
+	file := flag.String("file", "",
+		"file of length-delimited SubscribeResponses to replay, as written by "+
+			"gnmi -outfile (REQUIRED)")
+	mode := flag.String("mode", modeCollector,
+		"'collector' to publish the responses to the gNMIReverse collector at "+
+			"-collector_addr, 'target' to serve them to the gNMI subscribers of -addr")
+	collectorAddr := flag.String("collector_addr", "127.0.0.1:6035",
+		"address of the gNMIReverse collector")
+	addr := flag.String("addr", "127.0.0.1:6030", "address to listen on in target mode")
+	useTLS := flag.Bool("tls", false, "use TLS with the collector or the subscribers")
+	skipVerify := flag.Bool("tls_skipverify", false,
+		"don't verify the collector's certificate")
+	certFile := flag.String("certfile", "", "path to TLS certificate file")
+	keyFile := flag.String("keyfile", "", "path to TLS key file")
+	caFile := flag.String("cafile", "", "path to TLS CA file to verify the collector")
+	speed := flag.Float64("speed", 1,
+		"factor by which the time between the recorded notifications is divided, "+
+			"e.g. 10 to replay them ten times faster, or 0 to replay them as fast as possible")
+	loops := flag.Int("loop", 1, "number of times to replay the file, 0 to loop forever")
+	rewriteTimestamps := flag.Bool("rewrite_timestamps", false,
+		"set the timestamps of the notifications to the time they are replayed")
+	flag.Parse()
+
+	if *file == "" {
+		glog.Fatal("-file is required")
+	}
+	if *speed < 0 {
+		glog.Fatal("-speed must not be negative")
+	}
+	if *loops < 0 {
+		glog.Fatal("-loop must not be negative")
+	}
+	r := newReplayer(*file, *speed, *loops, *rewriteTimestamps)
+
+	var tlsConfig *tls.Config
+	if *useTLS {
+		var err error
+		tlsConfig, err = newTLSConfig(*mode == modeCollector, *skipVerify,
+			*certFile, *keyFile, *caFile)
+		if err != nil {
+			glog.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	var err error
+	switch *mode {
+	case modeCollector:
+		err = publish(ctx, r, *collectorAddr, tlsConfig)
+	case modeTarget:
+		err = serve(ctx, r, *addr, tlsConfig)
+	default:
+		glog.Fatalf("unknown mode %q", *mode)
+	}
+	if err != nil && ctx.Err() == nil {
+		glog.Fatal(err)
+	}
+}
+
+// publish replays the responses of r to the gNMIReverse collector at addr.
+func publish(ctx context.Context, r *replayer, addr string, tlsConfig *tls.Config) error {
+	dialOptions := []grpc.DialOption{grpc.WithBlock()}
+	if tlsConfig != nil {
+		dialOptions = append(dialOptions,
+			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOptions = append(dialOptions, grpc.WithInsecure())
+	}
+	conn, err := grpc.DialContext(ctx, addr, dialOptions...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %s", addr, err)
+	}
+	defer conn.Close()
+
+	stream, err := gnmireverse.NewGNMIReverseClient(conn).Publish(ctx)
+	if err != nil {
+		return fmt.Errorf("error from Publish: %s", err)
+	}
+	var sent int
+	if err := r.replay(ctx, func(resp *gnmi.SubscribeResponse) error {
+		if err := stream.Send(resp); err != nil {
+			return fmt.Errorf("error from Publish.Send: %s", err)
+		}
+		sent++
+		return nil
+	}); err != nil {
+		return err
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return fmt.Errorf("error closing the Publish stream: %s", err)
+	}
+	glog.Infof("Published %d responses to %s", sent, addr)
+	return nil
+}
+
+// serve serves the responses of r to the gNMI subscribers of addr until
+// ctx is canceled.
+func serve(ctx context.Context, r *replayer, addr string, tlsConfig *tls.Config) error {
+	var serverOptions []grpc.ServerOption
+	if tlsConfig != nil {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
+	gnmi.RegisterGNMIServer(grpcServer, &target{r: r})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		grpcServer.Stop()
+	}()
+	glog.Infof("Serving %s on %s", r.file, listener.Addr())
+	return grpcServer.Serve(listener)
+}
+
+// newTLSConfig returns the TLS configuration to dial a collector, if
+// client is true, or of the server of a target otherwise.
+func newTLSConfig(client, skipVerify bool, certFile, keyFile, caFile string) (*tls.Config,
+	error) {
+	var tlsConfig tls.Config
+	if client {
+		if skipVerify {
+			tlsConfig.InsecureSkipVerify = true
+		} else if caFile != "" {
+			b, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			cp := x509.NewCertPool()
+			if !cp.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("credentials: failed to append certificates")
+			}
+			tlsConfig.RootCAs = cp
+		}
+	} else if certFile == "" {
+		return nil, fmt.Errorf("-certfile and -keyfile are required with -tls in target mode")
+	}
+	if certFile != "" {
+		if keyFile == "" {
+			return nil, fmt.Errorf("please provide both -certfile and -keyfile")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &tlsConfig, nil
+}
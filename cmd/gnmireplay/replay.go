@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	gnmilib "github.com/aristanetworks/goarista/gnmi"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// replayer replays a file of length-delimited SubscribeResponses, as
+// written by the gnmi command with -outfile or -output proto.
+type replayer struct {
+	file string
+	// speed scales the time between the notifications: 2 replays them
+	// twice as fast as they were recorded, 0 as fast as possible.
+	speed float64
+	// loops is the number of times the file is replayed, 0 to replay
+	// it until the context is canceled.
+	loops int
+	// rewriteTimestamps sets the timestamp of the notifications to the
+	// time they are replayed.
+	rewriteTimestamps bool
+
+	// now and sleep are overridden in tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newReplayer(file string, speed float64, loops int, rewriteTimestamps bool) *replayer {
+	return &replayer{
+		file:              file,
+		speed:             speed,
+		loops:             loops,
+		rewriteTimestamps: rewriteTimestamps,
+		now:               time.Now,
+		sleep:             sleep,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// replay calls send with each SubscribeResponse of the file, spacing
+// the notifications by the difference of their timestamps scaled by
+// the speed, until the file was replayed the requested number of times.
+func (r *replayer) replay(ctx context.Context,
+	send func(*gnmi.SubscribeResponse) error) error {
+	for i := 0; r.loops == 0 || i < r.loops; i++ {
+		if err := r.replayOnce(ctx, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *replayer) replayOnce(ctx context.Context,
+	send func(*gnmi.SubscribeResponse) error) error {
+	f, err := os.Open(r.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rd := bufio.NewReader(f)
+	// The notifications are sent relative to the time the first one
+	// was sent, rather than to the previous one, so that the delays
+	// don't accumulate.
+	var start time.Time
+	var first int64
+	for n := 0; ; n++ {
+		var resp gnmi.SubscribeResponse
+		if err := gnmilib.ReadDelimited(rd, &resp); err != nil {
+			if err == io.EOF {
+				if n == 0 {
+					return fmt.Errorf("no SubscribeResponse in %s", r.file)
+				}
+				return nil
+			}
+			return fmt.Errorf("failed to read response %d of %s: %s", n, r.file, err)
+		}
+		if notif := resp.GetUpdate(); notif != nil && notif.Timestamp != 0 {
+			if start.IsZero() {
+				start, first = r.now(), notif.Timestamp
+			} else if r.speed > 0 {
+				offset := time.Duration(float64(notif.Timestamp-first) / r.speed)
+				if d := start.Add(offset).Sub(r.now()); d > 0 {
+					if err := r.sleep(ctx, d); err != nil {
+						return err
+					}
+				}
+			}
+			if r.rewriteTimestamps {
+				notif.Timestamp = r.now().UnixNano()
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(&resp); err != nil {
+			return err
+		}
+	}
+}
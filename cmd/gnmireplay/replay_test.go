@@ -0,0 +1,235 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gnmilib "github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/test"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+)
+
+func notification(ts int64) *gnmi.SubscribeResponse {
+	return &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{
+		Update: &gnmi.Notification{
+			Timestamp: ts,
+			Update: []*gnmi.Update{{
+				Path: &gnmi.Path{Elem: []*gnmi.PathElem{{Name: "a"}}},
+				Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: ts}},
+			}},
+		},
+	}}
+}
+
+var syncResponse = &gnmi.SubscribeResponse{
+	Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}}
+
+// writeCapture writes responses to a file in dir and returns its path.
+func writeCapture(t *testing.T, dir string, responses ...*gnmi.SubscribeResponse) string {
+	t.Helper()
+	name := filepath.Join(dir, "capture.pb")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, resp := range responses {
+		if err := gnmilib.WriteDelimited(f, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return name
+}
+
+// fakeClock is a clock advanced by sleeping.
+type fakeClock struct {
+	t      time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) sleep(_ context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.t = c.t.Add(d)
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnmireplay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeCapture(t, dir,
+		notification(1000), syncResponse, notification(1000+int64(time.Second)),
+		notification(1000+int64(3*time.Second)))
+
+	for name, tc := range map[string]struct {
+		speed             float64
+		loops             int
+		rewriteTimestamps bool
+		sleeps            []time.Duration
+		timestamps        []int64
+	}{
+		"recorded speed": {
+			speed:      1,
+			loops:      1,
+			sleeps:     []time.Duration{time.Second, 2 * time.Second},
+			timestamps: []int64{1000, 0, 1000 + int64(time.Second), 1000 + int64(3*time.Second)},
+		},
+		"faster": {
+			speed:      4,
+			loops:      1,
+			sleeps:     []time.Duration{250 * time.Millisecond, 500 * time.Millisecond},
+			timestamps: []int64{1000, 0, 1000 + int64(time.Second), 1000 + int64(3*time.Second)},
+		},
+		"as fast as possible": {
+			speed:      0,
+			loops:      1,
+			timestamps: []int64{1000, 0, 1000 + int64(time.Second), 1000 + int64(3*time.Second)},
+		},
+		"loops with rewritten timestamps": {
+			speed:             2,
+			loops:             2,
+			rewriteTimestamps: true,
+			sleeps: []time.Duration{500 * time.Millisecond, time.Second,
+				500 * time.Millisecond, time.Second},
+			timestamps: []int64{
+				0, 0, int64(500 * time.Millisecond), int64(1500 * time.Millisecond),
+				int64(1500 * time.Millisecond), 0, int64(2 * time.Second),
+				int64(3 * time.Second)},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Unix(0, 0)}
+			r := newReplayer(file, tc.speed, tc.loops, tc.rewriteTimestamps)
+			r.now, r.sleep = clock.now, clock.sleep
+			var timestamps []int64
+			if err := r.replay(context.Background(), func(resp *gnmi.SubscribeResponse) error {
+				timestamps = append(timestamps, resp.GetUpdate().GetTimestamp())
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if d := test.Diff(tc.sleeps, clock.sleeps); d != "" {
+				t.Errorf("unexpected sleeps: %s", d)
+			}
+			if d := test.Diff(tc.timestamps, timestamps); d != "" {
+				t.Errorf("unexpected timestamps: %s", d)
+			}
+		})
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnmireplay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	send := func(*gnmi.SubscribeResponse) error { return nil }
+
+	if err := newReplayer(filepath.Join(dir, "missing"), 0, 1, false).replay(
+		context.Background(), send); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	empty := writeCapture(t, dir)
+	if err := newReplayer(empty, 0, 0, false).replay(context.Background(),
+		send); err == nil {
+		t.Error("expected an error for an empty file")
+	}
+	if err := ioutil.WriteFile(empty, []byte{42, 1}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newReplayer(empty, 0, 1, false).replay(context.Background(),
+		send); err == nil {
+		t.Error("expected an error for a truncated file")
+	}
+
+	// Looping forever stops when the context is canceled
+	file := writeCapture(t, dir, notification(1), notification(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	if err := newReplayer(file, 0, 0, false).replay(ctx, func(*gnmi.SubscribeResponse) error {
+		if n++; n == 5 {
+			cancel()
+		}
+		return nil
+	}); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 responses, got %d", n)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnmireplay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writeCapture(t, dir, notification(1), syncResponse, notification(2))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	gnmi.RegisterGNMIServer(s, &target{r: newReplayer(file, 0, 2, false)})
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := gnmi.NewGNMIClient(conn)
+
+	for mode, expected := range map[gnmi.SubscriptionList_Mode][]int64{
+		gnmi.SubscriptionList_ONCE:   {1, 0, 2},
+		gnmi.SubscriptionList_STREAM: {1, 0, 2, 1, 0, 2},
+	} {
+		t.Run(mode.String(), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			stream, err := client.Subscribe(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Send(&gnmi.SubscribeRequest{
+				Request: &gnmi.SubscribeRequest_Subscribe{
+					Subscribe: &gnmi.SubscriptionList{Mode: mode},
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var timestamps []int64
+			for range expected {
+				resp, err := stream.Recv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				timestamps = append(timestamps, resp.GetUpdate().GetTimestamp())
+			}
+			if d := test.Diff(expected, timestamps); d != "" {
+				t.Errorf("unexpected timestamps: %s", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+
+	"github.com/aristanetworks/glog"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// target is a fake gNMI target replaying the file to each subscriber,
+// regardless of the paths it subscribed to.
+type target struct {
+	gnmi.UnimplementedGNMIServer
+	r *replayer
+}
+
+func (t *target) Capabilities(context.Context,
+	*gnmi.CapabilityRequest) (*gnmi.CapabilityResponse, error) {
+	return &gnmi.CapabilityResponse{
+		SupportedEncodings: []gnmi.Encoding{gnmi.Encoding_JSON, gnmi.Encoding_JSON_IETF},
+		GNMIVersion:        "0.7.0",
+	}, nil
+}
+
+func (t *target) Subscribe(stream gnmi.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	subList := req.GetSubscribe()
+	if subList == nil {
+		return status.Error(codes.InvalidArgument, "first request is not a SubscriptionList")
+	}
+	if subList.Mode == gnmi.SubscriptionList_POLL {
+		return status.Error(codes.Unimplemented, "POLL subscriptions are not supported")
+	}
+	ctx := stream.Context()
+	var source string
+	if p, ok := peer.FromContext(ctx); ok {
+		source = p.Addr.String()
+	}
+	glog.Infof("Replaying %s to %s", t.r.file, source)
+
+	r := *t.r
+	if subList.Mode == gnmi.SubscriptionList_ONCE {
+		// A ONCE subscription is closed after the data was sent once
+		r.loops = 1
+	}
+	if err := r.replay(ctx, stream.Send); err != nil {
+		if ctx.Err() != nil {
+			// The subscriber went away
+			return nil
+		}
+		glog.Errorf("Failed to replay %s to %s: %s", t.r.file, source, err)
+		return status.Error(codes.Internal, err.Error())
+	}
+	glog.Infof("Done replaying %s to %s", t.r.file, source)
+	if subList.Mode == gnmi.SubscriptionList_ONCE {
+		return nil
+	}
+	// Keep the STREAM subscription open, as a target would
+	<-ctx.Done()
+	return nil
+}
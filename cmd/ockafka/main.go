@@ -15,8 +15,10 @@ import (
 
 	client "github.com/aristanetworks/goarista/gnmi"
 	"github.com/aristanetworks/goarista/kafka"
+	"github.com/aristanetworks/goarista/kafka/avro"
 	"github.com/aristanetworks/goarista/kafka/gnmi"
 	"github.com/aristanetworks/goarista/kafka/producer"
+	"github.com/aristanetworks/goarista/logger"
 
 	pb "github.com/openconfig/gnmi/proto/gnmi"
 
@@ -28,9 +30,40 @@ var keysFlag = flag.String("kafkakeys", "",
 	"Keys for kafka messages (comma-separated, default: the value of -addrs). The key '"+
 		client.HostnameArg+"' is replaced by the current hostname.")
 
-func newProducer(addresses []string, topic, key, dataset string) (producer.Producer, error) {
+var (
+	encodingFlag = flag.String("kafkaencoding", "json",
+		"Encoding of kafka messages: json or avro")
+	schemaRegistryFlag = flag.String("schemaregistry", "",
+		"URL of the Confluent Schema Registry holding the schema of avro messages")
+	subjectNamingFlag = flag.String("schemasubjectnaming", string(avro.TopicNameStrategy),
+		"Subject name strategy of the schema of avro messages: "+
+			"topic (<topic>-value), record (the record name) or "+
+			"topic_record (<topic>-<record name>)")
+	autoRegisterFlag = flag.Bool("schemaautoregister", true,
+		"Register the schema of avro messages in the schema registry "+
+			"if it isn't already registered")
+)
+
+func newEncoder(registry *avro.Registry, topic, key, dataset string) (kafka.MessageEncoder,
+	error) {
 	encodedKey := sarama.StringEncoder(key)
-	p, err := producer.New(gnmi.NewEncoder(topic, encodedKey, dataset), addresses, nil)
+	switch *encodingFlag {
+	case "json":
+		return gnmi.NewEncoder(topic, encodedKey, dataset), nil
+	case "avro":
+		return avro.NewEncoder(topic, encodedKey, dataset, registry,
+			avro.SubjectNameStrategy(*subjectNamingFlag), *autoRegisterFlag)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", *encodingFlag)
+}
+
+func newProducer(registry *avro.Registry, addresses []string,
+	topic, key, dataset string) (producer.Producer, error) {
+	encoder, err := newEncoder(registry, topic, key, dataset)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Kafka encoder: %s", err)
+	}
+	p, err := producer.New(encoder, addresses, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create Kafka brokers: %s", err)
 	}
@@ -40,7 +73,9 @@ func newProducer(addresses []string, topic, key, dataset string) (producer.Produ
 
 func main() {
 	ctx := context.Background()
+	logger.RegisterFlags(flag.CommandLine)
 	config, subscriptions := client.ParseFlags()
+	logger.HandleSignals()
 	ctx = client.NewContext(ctx, config)
 	grpcAddrs := strings.Split(config.Addr, ",")
 
@@ -57,11 +92,18 @@ func main() {
 	if len(grpcAddrs) != len(keys) {
 		glog.Fatal("Please provide the same number of addresses and Kafka keys")
 	}
+	var registry *avro.Registry
+	if *encodingFlag == "avro" {
+		if *schemaRegistryFlag == "" {
+			glog.Fatal("Please provide the URL of the schema registry with -schemaregistry")
+		}
+		registry = avro.NewRegistry(*schemaRegistryFlag, nil)
+	}
 	addresses := strings.Split(*kafka.Addresses, ",")
 	wg := new(sync.WaitGroup)
 	for i, grpcAddr := range grpcAddrs {
 		key := keys[i]
-		p, err := newProducer(addresses, *kafka.Topic, key, grpcAddr)
+		p, err := newProducer(registry, addresses, *kafka.Topic, key, grpcAddr)
 		if err != nil {
 			glog.Fatal(err)
 		} else {
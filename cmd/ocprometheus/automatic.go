@@ -0,0 +1,196 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AutoMetricsDef is the representation of the automatic metrics
+// settings in the config file. When set, the updates that don't match
+// any of the configured metrics are exported as metrics named after
+// their path, with the keys of the path as labels. For example, an
+// update for
+// /interfaces/interface[name=Ethernet1]/state/counters/in-octets
+// becomes the metric interfaces_interface_state_counters_in_octets
+// with the label name="Ethernet1".
+//
+// String values are exported as info metrics, with the _info suffix,
+// a value of 1 and the string in the value label.
+type AutoMetricsDef struct {
+	// Prefix is prepended to the names of the metrics, followed by an
+	// underscore.
+	Prefix string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// sanitizeName turns s into a valid Prometheus metric or label name.
+func sanitizeName(s string) string {
+	// Strip the YANG module name, as in openconfig-interfaces:interfaces
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.Trim(invalidNameChars.ReplaceAllString(s, "_"), "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
+
+// autoPath returns the elems of the full path of an update, with the
+// elements of the value suffix returned by getValue appended.
+func autoPath(elems []*pb.PathElem, suffix string) []*pb.PathElem {
+	if suffix != "" {
+		for _, name := range strings.Split(suffix, "/") {
+			elems = append(elems, &pb.PathElem{Name: name})
+		}
+	}
+	return elems
+}
+
+// autoMetricName returns the name of the automatic metric for the
+// path elems, its help string, and the names and values of the labels
+// taken from the keys of the path. The help is derived from the name,
+// not the path: different paths, such as /a-b and /a_b, can have the
+// same name once sanitized, and Prometheus fails a scrape with
+// different help strings for the same metric name.
+func autoMetricName(prefix string, elems []*pb.PathElem) (string, string,
+	[]string, []string) {
+	names := make([]string, 0, len(elems)+1)
+	if prefix != "" {
+		names = append(names, sanitizeName(prefix))
+	}
+	var labelNames, labels []string
+	used := make(map[string]bool)
+	for _, elem := range elems {
+		name := sanitizeName(elem.Name)
+		names = append(names, name)
+
+		keys := make([]string, 0, len(elem.Key))
+		for k := range elem.Key {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			labelNames = append(labelNames, uniqueLabel(used, name, sanitizeName(k)))
+			labels = append(labels, elem.Key[k])
+		}
+	}
+	name := strings.Join(names, "_")
+	return name, "Automatic metric of the gNMI paths named " + name, labelNames, labels
+}
+
+// uniqueLabel returns a label name not in used, based on label and
+// qualified with the element name if needed, and adds it to used.
+func uniqueLabel(used map[string]bool, elem, label string) string {
+	name := label
+	if used[name] {
+		name = elem + "_" + label
+	}
+	for i := 2; used[name]; i++ {
+		name = elem + "_" + label + strconv.Itoa(i)
+	}
+	used[name] = true
+	return name
+}
+
+// getAutoMetricValues returns the automatic metric of the update at
+// the path elems from device, or nil if the device doesn't have
+// labels while other devices do.
+func (c *Config) getAutoMetricValues(device string, elems []*pb.PathElem,
+	stringValue bool) *metricValues {
+	var constLabels prometheus.Labels
+	if len(c.DeviceLabels) > 0 {
+		var ok bool
+		if constLabels, ok = c.DeviceLabels[device]; !ok {
+			if constLabels, ok = c.DeviceLabels["*"]; !ok {
+				return nil
+			}
+		}
+	}
+	name, help, labelNames, labels := autoMetricName(c.AutoMetrics.Prefix, elems)
+	metric := &metricValues{labels: labels}
+	if stringValue {
+		name += "_info"
+		used := make(map[string]bool, len(labelNames))
+		for _, l := range labelNames {
+			used[l] = true
+		}
+		labelNames = append(labelNames, uniqueLabel(used, "info", "value"))
+		metric.labels = append(metric.labels, "")
+		metric.defaultValue = 1
+		metric.stringMetric = true
+	}
+	metric.auto = &autoDesc{name: name, help: help, labelNames: labelNames,
+		constLabels: constLabels}
+	return metric
+}
+
+// autoDesc describes an automatic metric. Its descriptor is only
+// created when the metrics are collected, see collectAutoMetrics,
+// since it depends on the other metrics of the same name.
+type autoDesc struct {
+	name       string
+	help       string
+	labelNames []string
+	// constLabels are the labels of the device.
+	constLabels prometheus.Labels
+}
+
+// collectAutoMetrics sends the automatic metrics to ch. The metrics of
+// the same name, such as those of paths with different keys or of
+// devices with different labels, must have the same label names to be
+// gathered together, so each metric is given the label names of all
+// the metrics of its name. Prometheus treats an empty label as a
+// missing one, so the labels a metric doesn't have are left empty.
+func collectAutoMetrics(ch chan<- prometheus.Metric, metrics []*labelledMetric) {
+	byName := make(map[string][]*labelledMetric)
+	for _, m := range metrics {
+		byName[m.auto.name] = append(byName[m.auto.name], m)
+	}
+	for name, metrics := range byName {
+		index := make(map[string]int)
+		var labelNames []string
+		addLabel := func(l string) {
+			if _, ok := index[l]; !ok {
+				index[l] = -1
+				labelNames = append(labelNames, l)
+			}
+		}
+		for _, m := range metrics {
+			for l := range m.auto.constLabels {
+				addLabel(l)
+			}
+			for _, l := range m.auto.labelNames {
+				addLabel(l)
+			}
+		}
+		sort.Strings(labelNames)
+		for i, l := range labelNames {
+			index[l] = i
+		}
+		desc := prometheus.NewDesc(name, metrics[0].auto.help, labelNames, nil)
+		for _, m := range metrics {
+			labels := make([]string, len(labelNames))
+			// The keys of the path take precedence over the labels of
+			// the device
+			for l, v := range m.auto.constLabels {
+				labels[index[l]] = v
+			}
+			for i, l := range m.auto.labelNames {
+				labels[index[l]] = m.labels[i]
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, m.value,
+				labels...)
+		}
+	}
+}
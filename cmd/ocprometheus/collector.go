@@ -8,18 +8,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"path"
 	"strings"
 	"sync"
 
-	"github.com/aristanetworks/glog"
-	"github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/gnmi/leaves"
+	"github.com/aristanetworks/goarista/logger"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// collectorLog is the logger of the conversion of updates to metrics,
+// whose V level can be set with -vsubsystem=collector=N.
+var collectorLog = logger.New("collector")
+
 // A metric source.
 type source struct {
 	addr string
@@ -33,6 +36,11 @@ type labelledMetric struct {
 	labels       []string
 	defaultValue float64
 	stringMetric bool
+
+	// auto is set, instead of metric, for an automatic metric, whose
+	// value is kept in value.
+	auto  *autoDesc
+	value float64
 }
 
 type collector struct {
@@ -54,7 +62,7 @@ func newCollector(config *Config) *collector {
 func (c *collector) update(addr string, message proto.Message) {
 	resp, ok := message.(*pb.SubscribeResponse)
 	if !ok {
-		glog.Errorf("Unexpected type of message: %T", message)
+		collectorLog.Errorf("Unexpected type of message: %T", message)
 		return
 	}
 
@@ -64,102 +72,125 @@ func (c *collector) update(addr string, message proto.Message) {
 	}
 
 	device := strings.Split(addr, ":")[0]
-	prefix := gnmi.StrPath(notif.Prefix)
-	// Process deletes first
-	for _, del := range notif.Delete {
-		path := path.Join(prefix, gnmi.StrPath(del))
-		key := source{addr: device, path: path}
-		c.m.Lock()
-		if _, ok := c.metrics[key]; ok {
-			delete(c.metrics, key)
+	// The deletes are processed first
+	if err := leaves.ForEach(notif, func(leaf *leaves.Leaf) error {
+		if leaf.Delete {
+			c.delete(device, leaf.Path())
 		} else {
-			// TODO: replace this with a prefix tree
-			p := path + "/"
-			for k := range c.metrics {
-				if k.addr == device && strings.HasPrefix(k.path, p) {
-					delete(c.metrics, k)
-				}
-			}
+			c.set(device, leaf)
 		}
-		c.m.Unlock()
+		return nil
+	}); err != nil {
+		collectorLog.V(9).Infof("Ignoring update: %s", err)
 	}
+}
 
-	// Process updates next
-	for _, update := range notif.Update {
-		path := path.Join(prefix, gnmi.StrPath(update.Path))
-		value, suffix, ok := parseValue(update)
-		if !ok {
-			continue
+// delete removes the metrics of path, or under path, of device.
+func (c *collector) delete(device, path string) {
+	key := source{addr: device, path: path}
+	c.m.Lock()
+	if _, ok := c.metrics[key]; ok {
+		delete(c.metrics, key)
+	} else {
+		// TODO: replace this with a prefix tree
+		p := path + "/"
+		for k := range c.metrics {
+			if k.addr == device && strings.HasPrefix(k.path, p) {
+				delete(c.metrics, k)
+			}
 		}
+	}
+	c.m.Unlock()
+}
 
-		var strUpdate bool
-		var floatVal float64
-		var strVal string
-
-		switch v := value.(type) {
-		case float64:
-			strUpdate = false
-			floatVal = v
-		case string:
-			strUpdate = true
-			strVal = v
-		}
+// set updates or creates the metric of the update leaf of device.
+func (c *collector) set(device string, leaf *leaves.Leaf) {
+	path := leaf.Path()
+	value, suffix, ok := getValue(leaf.Value)
+	if !ok {
+		return
+	}
 
-		if suffix != "" {
-			path += "/" + suffix
-		}
+	var strUpdate bool
+	var floatVal float64
+	var strVal string
 
-		src := source{addr: device, path: path}
-		c.m.Lock()
-		// Use the cached labels and descriptor if available
-		if m, ok := c.metrics[src]; ok {
-			if strUpdate {
-				// Skip string updates for non string metrics
-				if !m.stringMetric {
-					c.m.Unlock()
-					continue
-				}
-				// Display a default value and replace the value label with the string value
-				floatVal = m.defaultValue
-				m.labels[len(m.labels)-1] = strVal
-			}
+	switch v := value.(type) {
+	case float64:
+		strUpdate = false
+		floatVal = v
+	case string:
+		strUpdate = true
+		strVal = v
+	}
 
-			m.metric = prometheus.MustNewConstMetric(m.metric.Desc(), prometheus.GaugeValue,
-				floatVal, m.labels...)
-			c.m.Unlock()
-			continue
+	if suffix != "" {
+		path += "/" + suffix
+	}
+
+	src := source{addr: device, path: path}
+	c.m.Lock()
+	// Use the cached labels and descriptor if available
+	if m, ok := c.metrics[src]; ok {
+		if strUpdate {
+			// Skip string updates for non string metrics
+			if !m.stringMetric {
+				c.m.Unlock()
+				return
+			}
+			// Display a default value and replace the value label with the string value
+			floatVal = m.defaultValue
+			m.labels[len(m.labels)-1] = strVal
 		}
 
-		c.m.Unlock()
-		// Get the descriptor and labels for this source
-		metric := c.config.getMetricValues(src)
-		if metric == nil || metric.desc == nil {
-			glog.V(8).Infof("Ignoring unmatched update %v at %s:%s with value %+v",
-				update, device, path, value)
-			continue
+		if m.auto != nil {
+			m.value = floatVal
+		} else {
+			m.metric = prometheus.MustNewConstMetric(m.metric.Desc(),
+				prometheus.GaugeValue, floatVal, m.labels...)
 		}
+		c.m.Unlock()
+		return
+	}
 
-		// if metric should be treated as a string
-		if metric.stringMetric {
-			if !strUpdate {
-				strVal = fmt.Sprintf("%.0f", floatVal)
-			}
-			floatVal = metric.defaultValue
-			metric.labels[len(metric.labels)-1] = strVal
+	c.m.Unlock()
+	// Get the descriptor and labels for this source
+	metric := c.config.getMetricValues(src)
+	if metric == nil && c.config.AutoMetrics != nil {
+		metric = c.config.getAutoMetricValues(device,
+			autoPath(leaf.GNMIPath().Elem, suffix), strUpdate)
+	}
+	if metric == nil || (metric.desc == nil && metric.auto == nil) {
+		collectorLog.V(8).Infof("Ignoring unmatched update at %s:%s with value %+v",
+			device, path, value)
+		return
+	}
+
+	// if metric should be treated as a string
+	if metric.stringMetric {
+		if !strUpdate {
+			strVal = fmt.Sprintf("%.0f", floatVal)
 		}
+		floatVal = metric.defaultValue
+		metric.labels[len(metric.labels)-1] = strVal
+	}
 
-		// Save the metric and labels in the cache
-		c.m.Lock()
-		lm := prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue,
+	// Save the metric and labels in the cache
+	lm := &labelledMetric{
+		labels:       metric.labels,
+		defaultValue: metric.defaultValue,
+		stringMetric: metric.stringMetric,
+		auto:         metric.auto,
+	}
+	if metric.auto != nil {
+		lm.value = floatVal
+	} else {
+		lm.metric = prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue,
 			floatVal, metric.labels...)
-		c.metrics[src] = &labelledMetric{
-			metric:       lm,
-			labels:       metric.labels,
-			defaultValue: metric.defaultValue,
-			stringMetric: metric.stringMetric,
-		}
-		c.m.Unlock()
 	}
+	c.m.Lock()
+	c.metrics[src] = lm
+	c.m.Unlock()
 }
 
 func getValue(intf interface{}) (interface{}, string, bool) {
@@ -171,12 +202,12 @@ func getValue(intf interface{}) (interface{}, string, bool) {
 		return float64(value), "", true
 	case float32:
 		return float64(value), "", true
-	case *pb.Decimal64:
-		val := gnmi.DecimalToFloat(value)
-		if math.IsInf(val, 0) || math.IsNaN(val) {
+	case float64:
+		// Decimal64 value
+		if math.IsInf(value, 0) || math.IsNaN(value) {
 			return 0, "", false
 		}
-		return val, "", true
+		return value, "", true
 	case json.Number:
 		valFloat, err := value.Float64()
 		if err != nil {
@@ -186,7 +217,7 @@ func getValue(intf interface{}) (interface{}, string, bool) {
 	case *any.Any:
 		return value.String(), "", true
 	case []interface{}:
-		glog.V(9).Infof("skipping array value")
+		collectorLog.V(9).Infof("skipping array value")
 	case map[string]interface{}:
 		if vIntf, ok := value["value"]; ok {
 			res, suffix, ok := getValue(vIntf)
@@ -203,33 +234,38 @@ func getValue(intf interface{}) (interface{}, string, bool) {
 	case string:
 		return value, "", true
 	default:
-		glog.V(9).Infof("Ignoring update with unexpected type: %T", value)
+		collectorLog.V(9).Infof("Ignoring update with unexpected type: %T", value)
 	}
 
 	return 0, "", false
 }
 
-// parseValue takes in an update and parses a value and suffix
-// Returns an interface that contains either a string or a float64 as well as a suffix
-// Unparseable updates return (0, empty string, false)
-func parseValue(update *pb.Update) (interface{}, string, bool) {
-	intf, err := gnmi.ExtractValue(update)
-	if err != nil {
-		return 0, "", false
-	}
-	return getValue(intf)
-}
-
 // Describe implements prometheus.Collector interface
+//
+// With automatic metrics, whose names and labels aren't known until
+// they are received, c deliberately describes no metric, which makes
+// it an unchecked collector: its metrics aren't checked against those
+// of other collectors when it is registered, and conflicts with them
+// are only reported when gathering. The automatic metrics of the same
+// name are kept consistent by collectAutoMetrics instead.
 func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	if c.config.AutoMetrics != nil {
+		return
+	}
 	c.config.getAllDescs(ch)
 }
 
 // Collect implements prometheus.Collector interface
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
 	c.m.Lock()
+	var autoMetrics []*labelledMetric
 	for _, m := range c.metrics {
-		ch <- m.metric
+		if m.auto != nil {
+			autoMetrics = append(autoMetrics, m)
+		} else {
+			ch <- m.metric
+		}
 	}
+	collectAutoMetrics(ch, autoMetrics)
 	c.m.Unlock()
 }
@@ -10,9 +10,11 @@ import (
 	"testing"
 
 	"github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/gnmi/leaves"
 	"github.com/aristanetworks/goarista/test"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func makeMetrics(cfg *Config, expValues map[source]float64, notification *pb.Notification,
@@ -35,8 +37,8 @@ func makeMetrics(cfg *Config, expValues map[source]float64, notification *pb.Not
 
 		// Handle string updates
 		if notification.Update != nil {
-			if update, err := findUpdate(notification, src.path); err == nil {
-				val, _, ok := parseValue(update)
+			if leaf, err := findLeaf(notification, src.path); err == nil {
+				val, _, ok := getValue(leaf.Value)
 				if !ok {
 					continue
 				}
@@ -66,20 +68,24 @@ func makeMetrics(cfg *Config, expValues map[source]float64, notification *pb.Not
 	return expMetrics
 }
 
-func findUpdate(notif *pb.Notification, path string) (*pb.Update, error) {
-	prefix := notif.Prefix
-	for _, v := range notif.Update {
-		var fullPath string
-		if prefix != nil {
-			fullPath = gnmi.StrPath(gnmi.JoinPaths(prefix, v.Path))
-		} else {
-			fullPath = gnmi.StrPath(v.Path)
-		}
-		if strings.Contains(path, fullPath) || path == fullPath {
-			return v, nil
+// findLeaf returns the leaf of notif at path, or under which path is,
+// as collector.update sees it.
+func findLeaf(notif *pb.Notification, path string) (*leaves.Leaf, error) {
+	var found *leaves.Leaf
+	if err := leaves.ForEach(notif, func(leaf *leaves.Leaf) error {
+		if found == nil && strings.Contains(path, leaf.Path()) {
+			// The leaf is reused for the next ones
+			l := *leaf
+			found = &l
 		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("Failed to find matching update for path %v", path)
 	}
-	return nil, fmt.Errorf("Failed to find matching update for path %v", path)
+	return found, nil
 }
 
 func makeResponse(notif *pb.Notification) *pb.SubscribeResponse {
@@ -419,3 +425,168 @@ metrics:
 	}
 
 }
+
+func TestAutoMetrics(t *testing.T) {
+	config := []byte(`
+autometrics:
+        prefix: oc
+metrics:
+        - name: fanSpeed
+          path: /Sysdb/environment/cooling/status/fan/speed/value
+          help: Fan Speed
+`)
+	cfg, err := parseConfig(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	coll := newCollector(cfg)
+
+	notif := &pb.Notification{
+		Prefix: makePath("/interfaces/interface[name=Ethernet1]"),
+		Update: []*pb.Update{
+			{
+				Path: makePath("state/counters/in-octets"),
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}},
+			},
+			{
+				Path: makePath("subinterfaces/subinterface[index=0]/state/oper-status"),
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "UP"}},
+			},
+			{
+				Path: makePath("openconfig-if-ethernet:ethernet/state/counters/" +
+					"in-8021q-frames"),
+				Val: &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: []byte("7")}},
+			},
+		},
+	}
+	coll.update("10.1.1.1:6042", makeResponse(notif))
+	notif = &pb.Notification{
+		Prefix: makePath("/network-instances/network-instance[name=default]/protocols/" +
+			"protocol[identifier=BGP][name=BGP]/bgp/neighbors/" +
+			"neighbor[neighbor-address=10.0.0.1]"),
+		Update: []*pb.Update{{
+			Path: makePath("state/session-state"),
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "ESTABLISHED"}},
+		}},
+	}
+	coll.update("10.1.1.1:6042", makeResponse(notif))
+	notif = &pb.Notification{
+		Prefix: makePath("/Sysdb/environment/cooling/status/fan"),
+		Update: []*pb.Update{{
+			Path: makePath("speed"),
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: []byte(`{"value": 45}`)}},
+		}},
+	}
+	coll.update("10.1.1.1:6042", makeResponse(notif))
+	// Paths that have the same name once sanitized are in the same
+	// metric, with the same help.
+	notif = &pb.Notification{
+		Update: []*pb.Update{
+			{
+				Path: makePath("/lldp/neighbor[id=1]/max-frame"),
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1}},
+			},
+			{
+				Path: makePath("/lldp/neighbor[id=2]/max_frame"),
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 2}},
+			},
+		},
+	}
+	coll.update("10.1.1.1:6042", makeResponse(notif))
+
+	expected := `
+# HELP fanSpeed Fan Speed
+# TYPE fanSpeed gauge
+fanSpeed 45
+# HELP oc_interfaces_interface_ethernet_state_counters_in_8021q_frames ` +
+		`Automatic metric of the gNMI paths named ` +
+		`oc_interfaces_interface_ethernet_state_counters_in_8021q_frames
+# TYPE oc_interfaces_interface_ethernet_state_counters_in_8021q_frames gauge
+oc_interfaces_interface_ethernet_state_counters_in_8021q_frames{name="Ethernet1"} 7
+# HELP oc_interfaces_interface_state_counters_in_octets ` +
+		`Automatic metric of the gNMI paths named oc_interfaces_interface_state_counters_in_octets
+# TYPE oc_interfaces_interface_state_counters_in_octets gauge
+oc_interfaces_interface_state_counters_in_octets{name="Ethernet1"} 42
+# HELP oc_interfaces_interface_subinterfaces_subinterface_state_oper_status_info ` +
+		`Automatic metric of the gNMI paths named ` +
+		`oc_interfaces_interface_subinterfaces_subinterface_state_oper_status
+# TYPE oc_interfaces_interface_subinterfaces_subinterface_state_oper_status_info gauge
+oc_interfaces_interface_subinterfaces_subinterface_state_oper_status_info` +
+		`{index="0",name="Ethernet1",value="UP"} 1
+# HELP oc_lldp_neighbor_max_frame Automatic metric of the gNMI paths named ` +
+		`oc_lldp_neighbor_max_frame
+# TYPE oc_lldp_neighbor_max_frame gauge
+oc_lldp_neighbor_max_frame{id="1"} 1
+oc_lldp_neighbor_max_frame{id="2"} 2
+# HELP oc_network_instances_network_instance_protocols_protocol_bgp_neighbors_neighbor_` +
+		`state_session_state_info Automatic metric of the gNMI paths named ` +
+		`oc_network_instances_network_instance_protocols_protocol_bgp_neighbors_neighbor_` +
+		`state_session_state
+# TYPE oc_network_instances_network_instance_protocols_protocol_bgp_neighbors_neighbor_` +
+		`state_session_state_info gauge
+oc_network_instances_network_instance_protocols_protocol_bgp_neighbors_neighbor_` +
+		`state_session_state_info{identifier="BGP",name="default",` +
+		`neighbor_address="10.0.0.1",protocol_name="BGP",value="ESTABLISHED"} 1
+`
+	if err := testutil.CollectAndCompare(coll, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAutoMetricsLabels(t *testing.T) {
+	config := []byte(`
+autometrics:
+        prefix: oc
+devicelabels:
+        10.1.1.1:
+                region: east
+        "*":
+                rack: r1
+`)
+	cfg, err := parseConfig(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	coll := newCollector(cfg)
+	// The same metric name with different keys, and devices with
+	// different labels
+	coll.update("10.1.1.1:6042", makeResponse(&pb.Notification{
+		Update: []*pb.Update{{
+			Path: makePath("/lldp/neighbor[id=1]/max-frame"),
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1}},
+		}},
+	}))
+	coll.update("10.1.1.2:6042", makeResponse(&pb.Notification{
+		Update: []*pb.Update{{
+			Path: makePath("/lldp/neighbor[name=foo]/max_frame"),
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 2}},
+		}},
+	}))
+
+	expected := `
+# HELP oc_lldp_neighbor_max_frame Automatic metric of the gNMI paths named ` +
+		`oc_lldp_neighbor_max_frame
+# TYPE oc_lldp_neighbor_max_frame gauge
+oc_lldp_neighbor_max_frame{id="1",name="",rack="",region="east"} 1
+oc_lldp_neighbor_max_frame{id="",name="foo",rack="r1",region=""} 2
+`
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(coll)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	for in, exp := range map[string]string{
+		"in-octets":                       "in_octets",
+		"openconfig-interfaces:interface": "interface",
+		"8021q":                           "_8021q",
+		"a.b/c":                           "a_b_c",
+		"-":                               "_",
+	} {
+		if got := sanitizeName(in); got != exp {
+			t.Errorf("sanitizeName(%q): expected %q, got %q", in, exp, got)
+		}
+	}
+}
@@ -25,6 +25,10 @@ type Config struct {
 	// Metrics to collect and how to munge them.
 	Metrics []*MetricDef
 
+	// Automatic metrics for the updates not matching any of Metrics.
+	// Disabled if not set.
+	AutoMetrics *AutoMetricsDef
+
 	// Subscribed paths by their origin
 	subsByOrigin map[string][]string
 }
@@ -63,7 +67,9 @@ type MetricDef struct {
 
 // metricValues contains the values used in updating a metric
 type metricValues struct {
-	desc         *prometheus.Desc
+	desc *prometheus.Desc
+	// auto is set instead of desc for an automatic metric.
+	auto         *autoDesc
 	labels       []string
 	defaultValue float64
 	stringMetric bool
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/logger"
 
 	"github.com/aristanetworks/glog"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
@@ -39,7 +40,9 @@ func main() {
 	configFlag := flag.String("config", "",
 		"Config to turn OpenConfig telemetry into Prometheus metrics")
 
+	logger.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	logger.HandleSignals()
 	subscriptions := strings.Split(*subscribePaths, ",")
 	if *configFlag == "" {
 		glog.Fatal("You need specify a config file using -config flag")
@@ -80,6 +83,7 @@ func main() {
 		go handleSubscription(ctx, client, subscribeOptions, respChan, coll, gNMIcfg.Addr)
 	}
 	http.Handle(*url, promhttp.Handler())
+	http.Handle("/debug/loglevel", logger.Handler())
 	glog.Fatal(http.ListenAndServe(*listenaddr, nil))
 }
 
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/gnmi/leaves"
 
 	"github.com/aristanetworks/glog"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
@@ -134,17 +135,19 @@ func pushToOpenTSDB(addr string, conn OpenTSDBConn, config *Config, notif *pb.No
 			return
 		}
 	}
-	prefix := gnmi.StrPath(notif.Prefix)
-	for _, update := range notif.Update {
-		path := prefix + gnmi.StrPath(update.Path)
+	err := leaves.ForEach(notif, func(leaf *leaves.Leaf) error {
+		if leaf.Delete {
+			return nil
+		}
+		path := leaf.Path()
 		metricName, tags, staticValueMap := config.Match(path)
 		if metricName == "" {
 			glog.V(8).Infof("Ignoring unmatched update at %s ", path)
-			continue
+			return nil
 		}
-		value := parseValue(update, staticValueMap)
+		value := parseValue(path, leaf.Value, staticValueMap)
 		if value == nil {
-			continue
+			return nil
 		}
 		tags["host"] = host
 		for i, v := range value {
@@ -161,18 +164,17 @@ func pushToOpenTSDB(addr string, conn OpenTSDBConn, config *Config, notif *pb.No
 				glog.Info("Failed to put datapoint: ", err)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		glog.Fatalf("Malformed update in %s: %s", notif, err)
 	}
 }
 
-// parseValue returns either an integer/floating point value of the given update, or if
+// parseValue returns either an integer/floating point value of the update at path, or if
 // the value is a slice of integers/floating point values. If the value is neither of these
 // or if any element in the slice is non numerical, parseValue returns nil.
-func parseValue(update *pb.Update, staticValueMap map[string]int64) []interface{} {
-	value, err := gnmi.ExtractValue(update)
-	if err != nil {
-		glog.Fatalf("Malformed JSON update %q in %s", update.Val.GetJsonVal(), update)
-	}
-
+func parseValue(path string, value interface{}, staticValueMap map[string]int64) []interface{} {
 	switch value := value.(type) {
 	case int64:
 		return []interface{}{value}
@@ -180,14 +182,14 @@ func parseValue(update *pb.Update, staticValueMap map[string]int64) []interface{
 		return []interface{}{value}
 	case float32:
 		return []interface{}{value}
-	case *pb.Decimal64:
-		val := gnmi.DecimalToFloat(value)
-		if math.IsInf(val, 0) || math.IsNaN(val) {
+	case float64:
+		// Decimal64 value
+		if math.IsInf(value, 0) || math.IsNaN(value) {
 			return nil
 		}
-		return []interface{}{val}
+		return []interface{}{value}
 	case json.Number:
-		return []interface{}{parseNumber(value, update)}
+		return []interface{}{parseNumber(path, value)}
 	case []interface{}:
 		for i, val := range value {
 			switch val := val.(type) {
@@ -197,17 +199,17 @@ func parseValue(update *pb.Update, staticValueMap map[string]int64) []interface{
 				value[i] = val
 			case float32:
 				value[i] = val
-			case *pb.Decimal64:
-				v := gnmi.DecimalToFloat(val)
-				if math.IsInf(v, 0) || math.IsNaN(v) {
+			case float64:
+				if math.IsInf(val, 0) || math.IsNaN(val) {
 					value[i] = nil
+				} else {
+					value[i] = val
 				}
-				value[i] = v
 			case json.Number:
-				value[i] = parseNumber(val, update)
+				value[i] = parseNumber(path, val)
 			case map[string]interface{}:
 				if num, ok := val["value"].(json.Number); ok && len(val) == 1 {
-					value[i] = parseNumber(num, update)
+					value[i] = parseNumber(path, num)
 				}
 			case string:
 				return parseString(val, staticValueMap)
@@ -222,13 +224,13 @@ func parseValue(update *pb.Update, staticValueMap map[string]int64) []interface{
 		// Special case for simple value types that just have a "value"
 		// attribute (common case).
 		if val, ok := value["value"].(json.Number); ok && len(value) == 1 {
-			return []interface{}{parseNumber(val, update)}
+			return []interface{}{parseNumber(path, val)}
 		}
 	case string:
 		return parseString(value, staticValueMap)
 
 	default:
-		glog.V(9).Infof("Ignoring non-numeric or non-numeric slice value in %s", update)
+		glog.V(9).Infof("Ignoring non-numeric or non-numeric slice value %v at %s", value, path)
 	}
 	return nil
 }
@@ -244,7 +246,7 @@ func parseString(value string, staticValueMap map[string]int64) []interface{} {
 }
 
 // Convert our json.Number to either an int64, uint64, or float64.
-func parseNumber(num json.Number, update *pb.Update) interface{} {
+func parseNumber(path string, num json.Number) interface{} {
 	var value interface{}
 	var err error
 	if value, err = num.Int64(); err != nil {
@@ -254,7 +256,7 @@ func parseNumber(num json.Number, update *pb.Update) interface{} {
 		} else {
 			value, err = num.Float64()
 			if err != nil {
-				glog.Fatalf("Malformed JSON number %q in %s", num, update)
+				glog.Fatalf("Malformed JSON number %q at %s", num, path)
 			}
 		}
 	}
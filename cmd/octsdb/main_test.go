@@ -8,7 +8,9 @@ import (
 	"math"
 	"testing"
 
+	"github.com/aristanetworks/goarista/gnmi/leaves"
 	"github.com/aristanetworks/goarista/test"
+
 	pb "github.com/openconfig/gnmi/proto/gnmi"
 )
 
@@ -42,11 +44,18 @@ func TestParseValue(t *testing.T) { // Because parsing JSON sucks.
 		{`"default"`, map[string]int64{"default": 0}, []interface{}{int64(0)}},
 	}
 	for i, tcase := range testcases {
-		actual := parseValue(&pb.Update{
+		var value interface{}
+		if err := leaves.ForEach(&pb.Notification{Update: []*pb.Update{{
 			Val: &pb.TypedValue{
 				Value: &pb.TypedValue_JsonVal{JsonVal: []byte(tcase.input)},
 			},
-		}, tcase.staticValueMap)
+		}}}, func(leaf *leaves.Leaf) error {
+			value = leaf.Value
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		actual := parseValue("/", value, tcase.staticValueMap)
 		if d := test.Diff(tcase.expected, actual); d != "" {
 			t.Errorf("#%d: %s: %#v vs %#v", i, d, tcase.expected, actual)
 		}
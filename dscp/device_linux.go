@@ -0,0 +1,29 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package dscp
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// SetBindToDevice binds the socket to the network interface named
+// device with SO_BINDTODEVICE, so that it only sends and receives
+// packets over that interface. It's intended to be used in a
+// net.Dialer's or net.ListenConfig's Control function.
+func SetBindToDevice(c syscall.RawConn, device string) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = unix.BindToDevice(int(fd), device)
+	}); cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return os.NewSyscallError("setsockopt", err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// +build !linux
+
+package dscp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// SetBindToDevice binds the socket to the network interface named
+// device. It is only supported on Linux.
+func SetBindToDevice(c syscall.RawConn, device string) error {
+	return errors.New("binding a socket to a device is not supported on this platform")
+}
@@ -33,6 +33,35 @@ func ListenTCPWithTOS(address *net.TCPAddr, tos byte) (*net.TCPListener, error)
 	return lsnr.(*net.TCPListener), err
 }
 
+// ListenTCPWithTOSAndDevice is like ListenTCPWithTOS, but with the
+// socket also bound to the network interface named device, see
+// SetBindToDevice. If device is empty, the socket isn't bound to any
+// interface.
+func ListenTCPWithTOSAndDevice(address *net.TCPAddr, tos byte,
+	device string) (*net.TCPListener, error) {
+	cfg := net.ListenConfig{Control: Control(tos, device)}
+	lsnr, err := cfg.Listen(context.Background(), "tcp", address.String())
+	if err != nil {
+		return nil, err
+	}
+	return lsnr.(*net.TCPListener), err
+}
+
+// Control returns a function setting the given ToS on a socket, and
+// binding it to the network interface named device unless device is
+// empty. It's intended to be used as the Control function of a
+// net.Dialer or net.ListenConfig.
+func Control(tos byte, device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if device != "" {
+			if err := SetBindToDevice(c, device); err != nil {
+				return err
+			}
+		}
+		return SetTOS(network, c, tos)
+	}
+}
+
 // SetTOS will set the TOS byte on a unix system. It's intended to be
 // used in a net.Dialer's Control function.
 func SetTOS(network string, c syscall.RawConn, tos byte) error {
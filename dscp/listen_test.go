@@ -6,9 +6,13 @@ package dscp_test
 
 import (
 	"net"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/aristanetworks/goarista/dscp"
+
+	"golang.org/x/sys/unix"
 )
 
 func TestListenTCPWithTOS(t *testing.T) {
@@ -60,3 +64,86 @@ func testListenTCPWithTOS(t *testing.T, ip string) {
 	conn.Write(buf)
 	<-done
 }
+
+// getTOS returns the IPv4 TOS byte, or the IPv6 traffic class if ipv6 is set, of conn.
+func getTOS(t *testing.T, conn net.Conn, ipv6 bool) int {
+	rc, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		if ipv6 {
+			tos, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS)
+		} else {
+			tos, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	return tos
+}
+
+func TestNewListener(t *testing.T) {
+	for name, tc := range map[string]struct {
+		addr string
+		ipv6 bool
+	}{
+		"ipv4": {addr: "127.0.0.1:0"},
+		"ipv6": {addr: "[::1]:0", ipv6: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l, err := net.Listen("tcp", tc.addr)
+			if err != nil {
+				t.Skipf("Can't listen on %s: %s", tc.addr, err)
+			}
+			listen := dscp.NewListener(l, 40)
+			defer listen.Close()
+
+			conn, err := net.Dial(listen.Addr().Network(), listen.Addr().String())
+			if err != nil {
+				t.Fatal("Connection failed:", err)
+			}
+			defer conn.Close()
+			accepted, err := listen.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer accepted.Close()
+			if tos := getTOS(t, accepted, tc.ipv6); tos != 40 {
+				t.Errorf("Expected ToS 40 on the accepted connection, got %d", tos)
+			}
+		})
+	}
+}
+
+func TestListenTCPWithTOSAndDevice(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0}
+	listen, err := dscp.ListenTCPWithTOSAndDevice(addr, 40, "lo")
+	if err != nil {
+		if os.IsPermission(err) || strings.Contains(err.Error(), "not supported") {
+			t.Skipf("Can't bind to a device: %s", err)
+		}
+		t.Fatal(err)
+	}
+	defer listen.Close()
+
+	d := net.Dialer{Control: dscp.Control(40, "lo")}
+	conn, err := d.Dial("tcp", listen.Addr().String())
+	if err != nil {
+		t.Fatal("Connection failed:", err)
+	}
+	defer conn.Close()
+	if tos := getTOS(t, conn, false); tos != 40 {
+		t.Errorf("Expected ToS 40 on the dialed connection, got %d", tos)
+	}
+
+	if _, err := dscp.ListenTCPWithTOSAndDevice(addr, 40, "nonexistent0"); err == nil {
+		t.Error("Expected an error binding to a nonexistent device")
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package dscp
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+type tosListener struct {
+	net.Listener
+	tos byte
+}
+
+// NewListener returns a net.Listener that sets the given ToS (Type of
+// Service) on the connections accepted by l, using the IPv4 TOS byte or
+// the IPv6 traffic class depending on the address family of each
+// connection. This is useful with listeners created elsewhere, e.g. by
+// another package, as the accepted connections may not inherit the ToS
+// of the listening socket.
+func NewListener(l net.Listener, tos byte) net.Listener {
+	return &tosListener{Listener: l, tos: tos}
+}
+
+// Accept waits for and returns the next connection, with its ToS set.
+func (l *tosListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return conn, nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get the socket of the connection: %s", err)
+	}
+	network := conn.LocalAddr().Network()
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		// IPv4 connections accepted by an IPv6 socket use the IPv4 TOS byte
+		if addr.IP.To4() != nil {
+			network = "tcp4"
+		} else {
+			network = "tcp6"
+		}
+	}
+	if err := SetTOS(network, rc, l.tos); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
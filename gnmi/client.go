@@ -20,6 +20,7 @@ import (
 	"github.com/aristanetworks/goarista/netns"
 	"github.com/golang/protobuf/proto"
 	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/encoding/gzip"
@@ -79,6 +80,9 @@ type SubscribeOptions struct {
 	Paths             [][]string
 	Origin            string
 	Target            string
+	// Extensions are attached to the SubscribeRequest, e.g. a History
+	// extension created with NewHistoryRangeExtension.
+	Extensions []*gnmi_ext.Extension
 }
 
 // ParseFlags reads arguments from stdin and returns a populated Config object and a list of
@@ -88,7 +92,8 @@ func ParseFlags() (*Config, []string) {
 	var (
 		addrsFlag = flag.String("addrs", "localhost:6030",
 			"Comma-separated list of addresses of OpenConfig gRPC servers. The address 'HOSTNAME' "+
-				"is replaced by the current hostname.")
+				"is replaced by the current hostname. An address of the form unix:///path "+
+				"connects to a unix domain socket.")
 
 		caFileFlag = flag.String("cafile", "",
 			"Path to server TLS certificate file")
@@ -134,6 +139,50 @@ func ParseFlags() (*Config, []string) {
 
 }
 
+// unixAddrPrefix is the prefix of unix domain socket addresses, as in
+// unix:///var/run/gnmi.sock.
+const unixAddrPrefix = "unix://"
+
+// ParseUnixAddress returns the path of the socket and true if addr is a
+// unix domain socket address of the form unix:///path/to/socket.
+func ParseUnixAddress(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, unixAddrPrefix), true
+}
+
+// VerifyCertificateChain returns a function to use as
+// tls.Config.VerifyPeerCertificate, along with InsecureSkipVerify,
+// when there is no server name to verify the peer's certificate
+// against, such as when connecting over a unix domain socket. The
+// function verifies that the certificate is signed by one of roots,
+// or by the host's root CA set if roots is nil.
+func VerifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no certificate presented by the server")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse certificate: %s", err)
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
+
 // accessTokenCred implements credentials.PerRPCCredentials, the gRPC
 // interface for credentials that need to attach security information
 // to every RPC.
@@ -169,6 +218,7 @@ func DialContext(ctx context.Context, cfg *Config) (pb.GNMIClient, error) {
 		return nil, fmt.Errorf("unsupported compression option: %q", cfg.Compression)
 	}
 
+	_, isUnix := ParseUnixAddress(cfg.Addr)
 	if cfg.TLS || cfg.CAFile != "" || cfg.CertFile != "" || cfg.Token != "" {
 		tlsConfig := &tls.Config{}
 		if cfg.CAFile != "" {
@@ -181,6 +231,12 @@ func DialContext(ctx context.Context, cfg *Config) (pb.GNMIClient, error) {
 				return nil, fmt.Errorf("credentials: failed to append certificates")
 			}
 			tlsConfig.RootCAs = cp
+			if isUnix {
+				// There is no host name to verify the certificate
+				// against, only verify that it is signed by the CA.
+				tlsConfig.InsecureSkipVerify = true
+				tlsConfig.VerifyPeerCertificate = VerifyCertificateChain(cp)
+			}
 		} else {
 			tlsConfig.InsecureSkipVerify = true
 		}
@@ -210,6 +266,11 @@ func DialContext(ctx context.Context, cfg *Config) (pb.GNMIClient, error) {
 		if l := len(split); l == 2 {
 			network = split[0]
 			addr = split[1]
+		} else if strings.HasPrefix(addrIn, "/") {
+			// The unix resolver of gRPC passes the path of the
+			// socket of a unix:// address without its scheme
+			network = "unix"
+			addr = addrIn
 		} else {
 			network = "tcp"
 			addr = split[0]
@@ -264,7 +325,8 @@ func NewContext(ctx context.Context, cfg *Config) context.Context {
 	return ctx
 }
 
-// NewGetRequest returns a GetRequest for the given paths
+// NewGetRequest returns a GetRequest for the given paths. origin is
+// set on the paths that don't specify their own origin.
 func NewGetRequest(paths [][]string, origin string) (*pb.GetRequest, error) {
 	req := &pb.GetRequest{
 		Path: make([]*pb.Path, len(paths)),
@@ -274,8 +336,10 @@ func NewGetRequest(paths [][]string, origin string) (*pb.GetRequest, error) {
 		if err != nil {
 			return nil, err
 		}
+		if gnmiPath.Origin == "" {
+			gnmiPath.Origin = origin
+		}
 		req.Path[i] = gnmiPath
-		req.Path[i].Origin = origin
 	}
 	return req, nil
 }
@@ -331,7 +395,9 @@ func NewSubscribeRequest(subscribeOptions *SubscribeOptions) (*pb.SubscribeReque
 		if err != nil {
 			return nil, err
 		}
-		gnmiPath.Origin = subscribeOptions.Origin
+		if gnmiPath.Origin == "" {
+			gnmiPath.Origin = subscribeOptions.Origin
+		}
 		subList.Subscription[i] = &pb.Subscription{
 			Path:              gnmiPath,
 			Mode:              streamMode,
@@ -341,5 +407,5 @@ func NewSubscribeRequest(subscribeOptions *SubscribeOptions) (*pb.SubscribeReque
 		}
 	}
 	return &pb.SubscribeRequest{Request: &pb.SubscribeRequest_Subscribe{
-		Subscribe: subList}}, nil
+		Subscribe: subList}, Extension: subscribeOptions.Extensions}, nil
 }
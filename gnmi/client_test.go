@@ -0,0 +1,75 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package gnmi
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+)
+
+func TestParseUnixAddress(t *testing.T) {
+	for addr, tc := range map[string]struct {
+		path string
+		ok   bool
+	}{
+		"unix:///var/run/gnmi.sock": {path: "/var/run/gnmi.sock", ok: true},
+		"localhost:6030":            {},
+		"mgmt/10.0.0.1:6030":        {},
+		"tcp://localhost:6030":      {},
+	} {
+		path, ok := ParseUnixAddress(addr)
+		if path != tc.path || ok != tc.ok {
+			t.Errorf("%s: expected (%q, %t), got (%q, %t)", addr, tc.path, tc.ok, path, ok)
+		}
+	}
+}
+
+type capabilitiesServer struct {
+	pb.UnimplementedGNMIServer
+}
+
+func (capabilitiesServer) Capabilities(context.Context,
+	*pb.CapabilityRequest) (*pb.CapabilityResponse, error) {
+	return &pb.CapabilityResponse{GNMIVersion: "0.7.0"}, nil
+}
+
+func TestDialUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnmi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gnmi.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterGNMIServer(s, capabilitiesServer{})
+	go s.Serve(l)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := DialContext(ctx, &Config{Addr: "unix://" + path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Capabilities(ctx, &pb.CapabilityRequest{}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatalf("Capabilities over %s: %s", path, err)
+	}
+	if resp.GNMIVersion != "0.7.0" {
+		t.Errorf("unexpected response %v", resp)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package gnmi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// maxDelimitedSize is the size above which ReadDelimited rejects a
+// message, to not allocate an arbitrary amount of memory for a corrupt
+// length.
+const maxDelimitedSize = 64 << 20
+
+// WriteDelimited writes msg to w in the protobuf binary format, prefixed
+// by its size as a varint, so that a stream of messages can be read back
+// with ReadDelimited. This is the format of writeDelimitedTo in the Java
+// and C++ protobuf libraries.
+func WriteDelimited(w io.Writer, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(b))
+	n := binary.PutUvarint(buf, uint64(len(b)))
+	// Write the message in a single call, so that messages aren't
+	// interleaved or truncated if w is shared or not buffered.
+	_, err = w.Write(append(buf[:n], b...))
+	return err
+}
+
+// ReadDelimited reads a message written by WriteDelimited from r into
+// msg. It returns io.EOF if r has no more messages, and
+// io.ErrUnexpectedEOF if its last message is truncated.
+func ReadDelimited(r *bufio.Reader, msg proto.Message) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if size > maxDelimitedSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum of %d bytes",
+			size, maxDelimitedSize)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(b, msg)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package gnmi
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestDelimited(t *testing.T) {
+	resps := []*pb.SubscribeResponse{
+		{Response: &pb.SubscribeResponse_Update{Update: &pb.Notification{
+			Timestamp: 42,
+			Update: []*pb.Update{{
+				Path: &pb.Path{Elem: []*pb.PathElem{{Name: "a"}}},
+				Val:  &pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: 1}},
+			}},
+		}}},
+		{Response: &pb.SubscribeResponse_SyncResponse{SyncResponse: true}},
+	}
+	var buf bytes.Buffer
+	for _, resp := range resps {
+		if err := WriteDelimited(&buf, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := buf.Bytes()
+
+	r := bufio.NewReader(bytes.NewReader(b))
+	for i, expected := range resps {
+		var resp pb.SubscribeResponse
+		if err := ReadDelimited(r, &resp); err != nil {
+			t.Fatalf("message %d: %s", i, err)
+		}
+		if !proto.Equal(expected, &resp) {
+			t.Errorf("message %d: expected %v, got %v", i, expected, &resp)
+		}
+	}
+	if err := ReadDelimited(r, &pb.SubscribeResponse{}); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	r = bufio.NewReader(bytes.NewReader(b[:len(b)-1]))
+	if err := ReadDelimited(r, &pb.SubscribeResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadDelimited(r, &pb.SubscribeResponse{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF for a truncated message, got %v", err)
+	}
+}
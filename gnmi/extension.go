@@ -0,0 +1,34 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package gnmi
+
+import (
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+)
+
+// NewHistorySnapshotExtension returns a History extension requesting
+// a snapshot of the state of the subscribed paths at time t, see
+// https://github.com/openconfig/reference/blob/master/rpc/gnmi/gnmi-history.md
+func NewHistorySnapshotExtension(t time.Time) *gnmi_ext.Extension {
+	return newHistoryExtension(&gnmi_ext.History{
+		Request: &gnmi_ext.History_SnapshotTime{SnapshotTime: t.UnixNano()},
+	})
+}
+
+// NewHistoryRangeExtension returns a History extension requesting the
+// updates of the subscribed paths between start and end.
+func NewHistoryRangeExtension(start, end time.Time) *gnmi_ext.Extension {
+	return newHistoryExtension(&gnmi_ext.History{
+		Request: &gnmi_ext.History_Range{
+			Range: &gnmi_ext.TimeRange{Start: start.UnixNano(), End: end.UnixNano()},
+		},
+	})
+}
+
+func newHistoryExtension(history *gnmi_ext.History) *gnmi_ext.Extension {
+	return &gnmi_ext.Extension{Ext: &gnmi_ext.Extension_History{History: history}}
+}
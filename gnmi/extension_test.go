@@ -0,0 +1,67 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package gnmi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+)
+
+func TestNewHistorySnapshotExtension(t *testing.T) {
+	ts := time.Unix(1600000000, 42)
+	ext := NewHistorySnapshotExtension(ts)
+	expected := &gnmi_ext.Extension{Ext: &gnmi_ext.Extension_History{
+		History: &gnmi_ext.History{
+			Request: &gnmi_ext.History_SnapshotTime{SnapshotTime: ts.UnixNano()},
+		},
+	}}
+	if !proto.Equal(expected, ext) {
+		t.Errorf("expected History extension %v, got %v", expected, ext)
+	}
+}
+
+func TestNewHistoryRangeExtension(t *testing.T) {
+	start, end := time.Unix(1600000000, 0), time.Unix(1600000060, 0)
+	ext := NewHistoryRangeExtension(start, end)
+	// The extension must survive a round trip on the wire
+	b, err := proto.Marshal(&pb.SubscribeRequest{Extension: []*gnmi_ext.Extension{ext}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req pb.SubscribeRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Extension) != 1 {
+		t.Fatalf("expected an extension, got %v", req.Extension)
+	}
+	timeRange := req.Extension[0].GetHistory().GetRange()
+	if timeRange.GetStart() != start.UnixNano() || timeRange.GetEnd() != end.UnixNano() {
+		t.Errorf("unexpected time range %v in %v", timeRange, req.Extension[0])
+	}
+}
+
+func TestNewSubscribeRequestExtensions(t *testing.T) {
+	ext := &gnmi_ext.Extension{Ext: &gnmi_ext.Extension_RegisteredExt{
+		RegisteredExt: &gnmi_ext.RegisteredExtension{
+			Id:  gnmi_ext.ExtensionID_EID_EXPERIMENTAL,
+			Msg: []byte("foo"),
+		},
+	}}
+	req, err := NewSubscribeRequest(&SubscribeOptions{
+		Paths:      [][]string{{"a"}},
+		Extensions: []*gnmi_ext.Extension{ext},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Extension) != 1 || !proto.Equal(req.Extension[0], ext) {
+		t.Errorf("expected extension %v, got %v", ext, req.Extension)
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// Package leaves converts gNMI Notifications into the updates and deletes
+// of their leaves, each with its full path and decoded value, for the
+// exporters that store values by path.
+//
+// The values of the updates are decoded to:
+//
+//	string, ascii:      string
+//	int:                int64
+//	uint:               uint64
+//	bool:               bool
+//	float:              float32
+//	decimal64:          float64
+//	bytes, proto_bytes: []byte
+//	leaflist:           []interface{} of the decoded elements
+//	json, json_ietf:    the decoded JSON value, with numbers as json.Number
+//	any:                *any.Any
+package leaves
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/key"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Leaf is an update or a delete of a Notification.
+type Leaf struct {
+	// Timestamp is the timestamp of the Notification, in nanoseconds
+	// since the epoch.
+	Timestamp int64
+	// Target is the target of the prefix of the Notification.
+	Target string
+	// Origin is the origin of the path of the leaf, or of the prefix
+	// if the path has none.
+	Origin string
+	// Delete is true if the leaf, or the subtree under its path, was
+	// deleted.
+	Delete bool
+	// Value is the decoded value of an update, nil for a delete.
+	Value interface{}
+	// TypedValue is the value of the update as received. With
+	// FlattenJSON, it is the JSON object of which Value is a member.
+	TypedValue *pb.TypedValue
+
+	elems   []*pb.PathElem
+	path    string
+	keyPath key.Path
+}
+
+// Path returns the path of the leaf, with the prefix of the
+// Notification, in the format of gnmi.StrPath, e.g.
+// /interfaces/interface[name=Ethernet1]/state/mtu.
+func (l *Leaf) Path() string {
+	if l.path == "" {
+		l.path = gnmi.StrPath(&pb.Path{Elem: l.elems})
+	}
+	return l.path
+}
+
+// KeyPath returns the path of the leaf as a key.Path, with the prefix
+// of the Notification. Each element of the path is a key of its name,
+// followed, if the element has keys, by a key of the
+// map[string]interface{} of its keys.
+func (l *Leaf) KeyPath() key.Path {
+	if l.keyPath == nil {
+		p := make(key.Path, 0, len(l.elems))
+		for _, elem := range l.elems {
+			p = append(p, key.New(elem.Name))
+			if len(elem.Key) > 0 {
+				keys := make(map[string]interface{}, len(elem.Key))
+				for k, v := range elem.Key {
+					keys[k] = v
+				}
+				p = append(p, key.New(keys))
+			}
+		}
+		l.keyPath = p
+	}
+	return l.keyPath
+}
+
+// GNMIPath returns the path of the leaf, with the prefix of the
+// Notification and the origin of the leaf.
+func (l *Leaf) GNMIPath() *pb.Path {
+	elems := make([]*pb.PathElem, len(l.elems))
+	copy(elems, l.elems)
+	return &pb.Path{Origin: l.Origin, Elem: elems}
+}
+
+// Option configures how Notifications are converted.
+type Option func(w *walker)
+
+// FlattenJSON splits the updates whose value is a JSON object into a
+// leaf per member, recursively, so that the update of a container
+// results in the leaves under it. The module prefixes of the member
+// names, as in the "openconfig-interfaces:mtu" member of a json_ietf
+// value, are left out of the paths. Arrays, such as lists and
+// leaf-lists, are left as values.
+func FlattenJSON() Option {
+	return func(w *walker) {
+		w.flattenJSON = true
+	}
+}
+
+type walker struct {
+	flattenJSON bool
+}
+
+// ForEach calls f with each delete, then each update, of notif, until f
+// returns an error. To avoid allocations the Leaf is reused from one
+// call of f to the next, so f must not retain it. The updates whose
+// value can't be decoded are skipped. ForEach returns the error from f,
+// or else the first error decoding a value.
+func ForEach(notif *pb.Notification, f func(*Leaf) error, opts ...Option) error {
+	var w walker
+	for _, opt := range opts {
+		opt(&w)
+	}
+	prefix, err := elems(notif.GetPrefix())
+	if err != nil {
+		return err
+	}
+	var leaf Leaf
+	var decodeErr error
+	// elemBuf holds the prefix and the path of the current leaf
+	elemBuf := make([]*pb.PathElem, len(prefix), len(prefix)+8)
+	copy(elemBuf, prefix)
+	reset := func(path *pb.Path) error {
+		pathElems, err := elems(path)
+		if err != nil {
+			return err
+		}
+		leaf = Leaf{
+			Timestamp: notif.Timestamp,
+			Target:    notif.GetPrefix().GetTarget(),
+			Origin:    path.GetOrigin(),
+			elems:     append(elemBuf[:len(prefix)], pathElems...),
+		}
+		if leaf.Origin == "" {
+			leaf.Origin = notif.GetPrefix().GetOrigin()
+		}
+		elemBuf = leaf.elems
+		return nil
+	}
+
+	for _, del := range notif.GetDelete() {
+		if err := reset(del); err != nil {
+			return err
+		}
+		leaf.Delete = true
+		if err := f(&leaf); err != nil {
+			return err
+		}
+	}
+	for _, update := range notif.GetUpdate() {
+		if err := reset(update.GetPath()); err != nil {
+			return err
+		}
+		val, err := decode(update)
+		if err != nil {
+			if decodeErr == nil {
+				decodeErr = fmt.Errorf("failed to decode the value at %s: %s", leaf.Path(), err)
+			}
+			continue
+		}
+		if obj, ok := val.(map[string]interface{}); ok && w.flattenJSON && isJSON(update.Val) {
+			if err := w.flatten(&leaf, obj, update.Val, f); err != nil {
+				return err
+			}
+			continue
+		}
+		leaf.Value, leaf.TypedValue = val, update.Val
+		if err := f(&leaf); err != nil {
+			return err
+		}
+	}
+	return decodeErr
+}
+
+// flatten calls f with a leaf for each member of obj, under the path of
+// parent.
+func (w *walker) flatten(parent *Leaf, obj map[string]interface{}, tv *pb.TypedValue,
+	f func(*Leaf) error) error {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	n := len(parent.elems)
+	for _, name := range names {
+		elem := &pb.PathElem{Name: name}
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			elem.Name = name[i+1:]
+		}
+		leaf := *parent
+		leaf.elems = append(parent.elems[:n:n], elem)
+		leaf.path, leaf.keyPath = "", nil
+		if child, ok := obj[name].(map[string]interface{}); ok {
+			if err := w.flatten(&leaf, child, tv, f); err != nil {
+				return err
+			}
+			continue
+		}
+		leaf.Value, leaf.TypedValue = obj[name], tv
+		if err := f(&leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Map returns the values of the updates of notif by path, and the paths
+// of its deletes, as returned by Leaf.Path. The updates whose value
+// can't be decoded are left out, and the first error decoding a value
+// is returned.
+func Map(notif *pb.Notification, opts ...Option) (map[string]interface{}, []string, error) {
+	updates := make(map[string]interface{}, len(notif.GetUpdate()))
+	var deletes []string
+	err := ForEach(notif, func(leaf *Leaf) error {
+		if leaf.Delete {
+			deletes = append(deletes, leaf.Path())
+		} else {
+			updates[leaf.Path()] = leaf.Value
+		}
+		return nil
+	}, opts...)
+	return updates, deletes, err
+}
+
+// Float returns the value of a leaf as a float64, if it is a number:
+// an int64, uint64, float32, float64 or json.Number, or a string of a
+// number, as int64, uint64 and decimal64 values are encoded in
+// json_ietf values. NaN and infinite values are not numbers.
+func Float(value interface{}) (float64, bool) {
+	var f float64
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case json.Number:
+		var err error
+		if f, err = v.Float64(); err != nil {
+			return 0, false
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &f); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// elems returns the elements of path, converting the elements of
+// paths from before gNMI 0.4.
+func elems(path *pb.Path) ([]*pb.PathElem, error) {
+	if path == nil {
+		return nil, nil
+	}
+	if len(path.Elem) == 0 && len(path.Element) != 0 {
+		p, err := gnmi.ParseGNMIElements(path.Element)
+		if err != nil {
+			return nil, err
+		}
+		return p.Elem, nil
+	}
+	return path.Elem, nil
+}
+
+func isJSON(val *pb.TypedValue) bool {
+	switch val.GetValue().(type) {
+	case *pb.TypedValue_JsonVal, *pb.TypedValue_JsonIetfVal:
+		return true
+	}
+	return false
+}
+
+func decode(update *pb.Update) (interface{}, error) {
+	if update.Val == nil && update.Value != nil {
+		// Value of gNMI before 0.4
+		return gnmi.ExtractValue(update)
+	}
+	return decodeTypedValue(update.Val)
+}
+
+func decodeTypedValue(val *pb.TypedValue) (interface{}, error) {
+	switch v := val.GetValue().(type) {
+	case nil:
+		return nil, nil
+	case *pb.TypedValue_StringVal:
+		return v.StringVal, nil
+	case *pb.TypedValue_AsciiVal:
+		return v.AsciiVal, nil
+	case *pb.TypedValue_IntVal:
+		return v.IntVal, nil
+	case *pb.TypedValue_UintVal:
+		return v.UintVal, nil
+	case *pb.TypedValue_BoolVal:
+		return v.BoolVal, nil
+	case *pb.TypedValue_FloatVal:
+		return v.FloatVal, nil
+	case *pb.TypedValue_DecimalVal:
+		return gnmi.DecimalToFloat(v.DecimalVal), nil
+	case *pb.TypedValue_BytesVal:
+		return v.BytesVal, nil
+	case *pb.TypedValue_ProtoBytes:
+		return v.ProtoBytes, nil
+	case *pb.TypedValue_LeaflistVal:
+		elems := make([]interface{}, len(v.LeaflistVal.GetElement()))
+		for i, elem := range v.LeaflistVal.GetElement() {
+			var err error
+			if elems[i], err = decodeTypedValue(elem); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	case *pb.TypedValue_JsonVal:
+		return decodeJSON(v.JsonVal)
+	case *pb.TypedValue_JsonIetfVal:
+		return decodeJSON(v.JsonIetfVal)
+	case *pb.TypedValue_AnyVal:
+		return v.AnyVal, nil
+	}
+	return nil, fmt.Errorf("unhandled type of value %T", val.GetValue())
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("malformed JSON %q: %s", b, err)
+	}
+	return v, nil
+}
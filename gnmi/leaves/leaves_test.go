@@ -0,0 +1,377 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package leaves
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+	"github.com/aristanetworks/goarista/test"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func elem(name string, keys ...string) *pb.PathElem {
+	e := &pb.PathElem{Name: name}
+	if len(keys) > 0 {
+		e.Key = make(map[string]string, len(keys)/2)
+		for i := 0; i < len(keys); i += 2 {
+			e.Key[keys[i]] = keys[i+1]
+		}
+	}
+	return e
+}
+
+func update(val *pb.TypedValue, elems ...*pb.PathElem) *pb.Update {
+	return &pb.Update{Path: &pb.Path{Elem: elems}, Val: val}
+}
+
+// leaf is the comparable representation of a Leaf in tests.
+type leaf struct {
+	timestamp int64
+	target    string
+	origin    string
+	path      string
+	delete    bool
+	value     interface{}
+}
+
+func collect(t *testing.T, notif *pb.Notification, opts ...Option) []leaf {
+	t.Helper()
+	var leaves []leaf
+	if err := ForEach(notif, func(l *Leaf) error {
+		leaves = append(leaves, leaf{
+			timestamp: l.Timestamp,
+			target:    l.Target,
+			origin:    l.Origin,
+			path:      l.Path(),
+			delete:    l.Delete,
+			value:     l.Value,
+		})
+		return nil
+	}, opts...); err != nil {
+		t.Fatal(err)
+	}
+	return leaves
+}
+
+func TestForEach(t *testing.T) {
+	prefix := &pb.Path{
+		Origin: "openconfig",
+		Target: "device1",
+		Elem:   []*pb.PathElem{elem("interfaces"), elem("interface", "name", "Ethernet1")},
+	}
+	for name, tc := range map[string]struct {
+		notif    *pb.Notification
+		opts     []Option
+		expected []leaf
+	}{
+		"scalars": {
+			notif: &pb.Notification{
+				Timestamp: 42,
+				Prefix:    prefix,
+				Update: []*pb.Update{
+					update(&pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "up"}},
+						elem("state"), elem("oper-status")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1500}},
+						elem("state"), elem("mtu")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: -1}},
+						elem("state"), elem("ifindex")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_BoolVal{BoolVal: true}},
+						elem("state"), elem("enabled")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_FloatVal{FloatVal: 0.5}},
+						elem("state"), elem("load")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_DecimalVal{
+						DecimalVal: &pb.Decimal64{Digits: -105, Precision: 2}}},
+						elem("state"), elem("temperature")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_LeaflistVal{
+						LeaflistVal: &pb.ScalarArray{Element: []*pb.TypedValue{
+							{Value: &pb.TypedValue_UintVal{UintVal: 10}},
+							{Value: &pb.TypedValue_UintVal{UintVal: 20}},
+						}}}},
+						elem("state"), elem("vlans")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_BytesVal{BytesVal: []byte{1}}},
+						elem("state"), elem("raw")),
+				},
+			},
+			expected: []leaf{
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/oper-status", false, "up"},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/mtu", false, uint64(1500)},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/ifindex", false, int64(-1)},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/enabled", false, true},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/load", false, float32(0.5)},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/temperature", false, -1.05},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/vlans", false,
+					[]interface{}{uint64(10), uint64(20)}},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/raw", false, []byte{1}},
+			},
+		},
+		"deletes first": {
+			notif: &pb.Notification{
+				Timestamp: 42,
+				Prefix:    prefix,
+				Update: []*pb.Update{
+					update(&pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "a"}},
+						elem("description")),
+				},
+				Delete: []*pb.Path{
+					{Elem: []*pb.PathElem{elem("config")}},
+					{Origin: "eos_native", Elem: []*pb.PathElem{elem("state")}},
+				},
+			},
+			expected: []leaf{
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/config", true, nil},
+				{42, "device1", "eos_native",
+					"/interfaces/interface[name=Ethernet1]/state", true, nil},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/description", false, "a"},
+			},
+		},
+		"no prefix and pre-0.4 paths": {
+			notif: &pb.Notification{
+				Timestamp: 1,
+				Update: []*pb.Update{{
+					Path: &pb.Path{Element: []string{"a", "b[k=v]"}},
+					Val:  &pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: 1}},
+				}},
+				Delete: []*pb.Path{{Element: []string{"c"}}},
+			},
+			expected: []leaf{
+				{1, "", "", "/c", true, nil},
+				{1, "", "", "/a/b[k=v]", false, int64(1)},
+			},
+		},
+		"json_ietf": {
+			notif: &pb.Notification{
+				Timestamp: 42,
+				Prefix:    prefix,
+				Update: []*pb.Update{
+					update(&pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{
+						JsonIetfVal: []byte(`{"openconfig-interfaces:mtu": 1500,` +
+							`"counters": {"in-octets": "42"}}`)}},
+						elem("state")),
+				},
+			},
+			expected: []leaf{
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state", false,
+					map[string]interface{}{
+						"openconfig-interfaces:mtu": json.Number("1500"),
+						"counters": map[string]interface{}{
+							"in-octets": "42",
+						},
+					}},
+			},
+		},
+		"flattened json_ietf": {
+			notif: &pb.Notification{
+				Timestamp: 42,
+				Prefix:    prefix,
+				Update: []*pb.Update{
+					update(&pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{
+						JsonIetfVal: []byte(`{"openconfig-interfaces:mtu": 1500,` +
+							`"counters": {"in-octets": "42"}, "vlans": [1, 2]}`)}},
+						elem("state")),
+					update(&pb.TypedValue{Value: &pb.TypedValue_JsonVal{
+						JsonVal: []byte(`"up"`)}},
+						elem("state"), elem("oper-status")),
+				},
+			},
+			opts: []Option{FlattenJSON()},
+			expected: []leaf{
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/counters/in-octets", false,
+					"42"},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/mtu", false,
+					json.Number("1500")},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/vlans", false,
+					[]interface{}{json.Number("1"), json.Number("2")}},
+				{42, "device1", "openconfig",
+					"/interfaces/interface[name=Ethernet1]/state/oper-status", false, "up"},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			leaves := collect(t, tc.notif, tc.opts...)
+			if len(leaves) != len(tc.expected) {
+				t.Fatalf("expected %d leaves, got %d: %#v", len(tc.expected), len(leaves),
+					leaves)
+			}
+			for i, l := range leaves {
+				if !test.DeepEqual(tc.expected[i], l) {
+					t.Errorf("leaf %d: expected %#v, got %#v", i, tc.expected[i], l)
+				}
+			}
+		})
+	}
+}
+
+func TestLeafPaths(t *testing.T) {
+	notif := &pb.Notification{
+		Prefix: &pb.Path{Origin: "openconfig",
+			Elem: []*pb.PathElem{elem("interfaces"), elem("interface", "name", "Ethernet1")}},
+		Update: []*pb.Update{
+			update(&pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 1}},
+				elem("state"), elem("mtu")),
+			update(&pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 2}},
+				elem("config"), elem("mtu")),
+		},
+	}
+	var keyPaths []key.Path
+	var gnmiPaths []*pb.Path
+	if err := ForEach(notif, func(l *Leaf) error {
+		keyPaths = append(keyPaths, l.KeyPath())
+		gnmiPaths = append(gnmiPaths, l.GNMIPath())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expectedKeyPaths := []key.Path{{
+		key.New("interfaces"), key.New("interface"),
+		key.New(map[string]interface{}{"name": "Ethernet1"}),
+		key.New("state"), key.New("mtu"),
+	}, {
+		key.New("interfaces"), key.New("interface"),
+		key.New(map[string]interface{}{"name": "Ethernet1"}),
+		key.New("config"), key.New("mtu"),
+	}}
+	if !test.DeepEqual(expectedKeyPaths, keyPaths) {
+		t.Errorf("expected key paths %v, got %v", expectedKeyPaths, keyPaths)
+	}
+	// The paths returned by GNMIPath are retained after the Leaf is reused
+	expectedGNMIPaths := []*pb.Path{{
+		Origin: "openconfig",
+		Elem: []*pb.PathElem{elem("interfaces"), elem("interface", "name", "Ethernet1"),
+			elem("state"), elem("mtu")},
+	}, {
+		Origin: "openconfig",
+		Elem: []*pb.PathElem{elem("interfaces"), elem("interface", "name", "Ethernet1"),
+			elem("config"), elem("mtu")},
+	}}
+	if d := test.Diff(expectedGNMIPaths, gnmiPaths); d != "" {
+		t.Errorf("unexpected gNMI paths: %s", d)
+	}
+}
+
+func TestForEachErrors(t *testing.T) {
+	notif := &pb.Notification{
+		Update: []*pb.Update{
+			update(&pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{
+				JsonIetfVal: []byte(`{"a":`)}}, elem("a")),
+			update(&pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: 1}}, elem("b")),
+		},
+	}
+	// The update that can't be decoded is skipped
+	var paths []string
+	if err := ForEach(notif, func(l *Leaf) error {
+		paths = append(paths, l.Path())
+		return nil
+	}); err == nil || !strings.Contains(err.Error(), "/a") {
+		t.Errorf("expected an error for the malformed JSON at /a, got %v", err)
+	}
+	if d := test.Diff([]string{"/b"}, paths); d != "" {
+		t.Errorf("unexpected leaves: %s", d)
+	}
+
+	notif.Update[0] = notif.Update[1]
+	errStop := errors.New("stop")
+	var calls int
+	if err := ForEach(notif, func(*Leaf) error {
+		calls++
+		return errStop
+	}); err != errStop {
+		t.Errorf("expected %v, got %v", errStop, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected ForEach to stop after 1 call, got %d", calls)
+	}
+}
+
+func TestMap(t *testing.T) {
+	updates, deletes, err := Map(&pb.Notification{
+		Prefix: &pb.Path{Elem: []*pb.PathElem{elem("a")}},
+		Update: []*pb.Update{
+			update(&pb.TypedValue{Value: &pb.TypedValue_JsonVal{
+				JsonVal: []byte(`{"c": true, "d": {"e": null}}`)}}, elem("b")),
+		},
+		Delete: []*pb.Path{{Elem: []*pb.PathElem{elem("f")}}},
+	}, FlattenJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUpdates := map[string]interface{}{"/a/b/c": true, "/a/b/d/e": nil}
+	if d := test.Diff(expectedUpdates, updates); d != "" {
+		t.Errorf("unexpected updates: %s", d)
+	}
+	if d := test.Diff([]string{"/a/f"}, deletes); d != "" {
+		t.Errorf("unexpected deletes: %s", d)
+	}
+}
+
+func TestFloat(t *testing.T) {
+	for _, tc := range []struct {
+		value    interface{}
+		expected float64
+		ok       bool
+	}{
+		{int64(-3), -3, true},
+		{uint64(3), 3, true},
+		{float32(0.5), 0.5, true},
+		{1.5, 1.5, true},
+		{json.Number("2.5"), 2.5, true},
+		{"18446744073709551615", 18446744073709551615, true},
+		{"up", 0, false},
+		{true, 0, false},
+		{math.NaN(), 0, false},
+		{math.Inf(1), 0, false},
+		{nil, 0, false},
+	} {
+		if f, ok := Float(tc.value); f != tc.expected || ok != tc.ok {
+			t.Errorf("Float(%#v): expected %v, %t, got %v, %t", tc.value, tc.expected, tc.ok,
+				f, ok)
+		}
+	}
+}
+
+func BenchmarkForEach(b *testing.B) {
+	notif := &pb.Notification{
+		Timestamp: 42,
+		Prefix: &pb.Path{
+			Elem: []*pb.PathElem{elem("interfaces"), elem("interface", "name", "Ethernet1"),
+				elem("state"), elem("counters")},
+		},
+	}
+	for _, counter := range []string{"in-octets", "out-octets", "in-pkts", "out-pkts",
+		"in-errors", "out-errors", "in-discards", "out-discards"} {
+		notif.Update = append(notif.Update, update(
+			&pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: 42}}, elem(counter)))
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var sum float64
+		if err := ForEach(notif, func(l *Leaf) error {
+			f, _ := Float(l.Value)
+			sum += f
+			return nil
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
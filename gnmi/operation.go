@@ -402,7 +402,9 @@ func newSetRequest(setOps []*Operation, exts ...*gnmi_ext.Extension) (*pb.SetReq
 		if err != nil {
 			return nil, err
 		}
-		p.Origin = op.Origin
+		if p.Origin == "" {
+			p.Origin = op.Origin
+		}
 
 		// Target must apply to the entire SetRequest.
 		if op.Target != "" {
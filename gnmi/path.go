@@ -44,10 +44,23 @@ func nextTokenIndex(path string) int {
 // https://github.com/openconfig/reference/blob/master/rpc/gnmi/gnmi-path-conventions.md
 // No validation is done. Behavior is undefined if path is an invalid
 // gnmi path. TODO: Do validation?
+//
+// A path that doesn't start with a slash may start with an origin, as
+// in "openconfig:/interfaces", in which case the first element
+// returned is the origin followed by ":/", e.g. "openconfig:/".
+// ParseGNMIElements sets the origin of the gnmi path from such an
+// element. A path that starts with a slash has no origin, so the first
+// element of "/a:/b" is "a:".
 func SplitPath(path string) []string {
 	var result []string
 	if len(path) > 0 && path[0] == '/' {
 		path = path[1:]
+	} else if i := nextTokenIndex(path); isOrigin(path[:i]) {
+		result = append(result, path[:i]+"/")
+		path = path[i:]
+		if len(path) > 0 && path[0] == '/' {
+			path = path[1:]
+		}
 	}
 	for len(path) > 0 {
 		i := nextTokenIndex(path)
@@ -71,6 +84,7 @@ func SplitPaths(paths []string) [][]string {
 
 // StrPath builds a human-readable form of a gnmi path.
 // e.g. /a/b/c[e=f]
+// The origin of the path is left out, see StrPathWithOrigin.
 func StrPath(path *pb.Path) string {
 	if path == nil {
 		return "/"
@@ -82,6 +96,17 @@ func StrPath(path *pb.Path) string {
 	return "/"
 }
 
+// StrPathWithOrigin is like StrPath, but if the path has an origin, it
+// is prepended followed by a colon, e.g. openconfig:/a/b/c[e=f], so
+// that the result can be parsed back with SplitPath and
+// ParseGNMIElements.
+func StrPathWithOrigin(path *pb.Path) string {
+	if origin := path.GetOrigin(); origin != "" {
+		return origin + ":" + StrPath(path)
+	}
+	return StrPath(path)
+}
+
 // strPathV04 handles the v0.4 gnmi and later path.Elem member.
 func strPathV04(path *pb.Path) string {
 	b := &strings.Builder{}
@@ -147,8 +172,17 @@ func writeSafeString(b *strings.Builder, s string, esc rune) {
 	}
 }
 
-// ParseGNMIElements builds up a gnmi path, from user-supplied text
+// ParseGNMIElements builds up a gnmi path, from user-supplied text.
+// If the first element is an origin of the form "origin:/", as
+// returned by SplitPath, it sets the origin of the path.
 func ParseGNMIElements(elms []string) (*pb.Path, error) {
+	var origin string
+	if len(elms) > 0 {
+		if o, ok := parseOrigin(elms[0]); ok {
+			origin = o
+			elms = elms[1:]
+		}
+	}
 	var parsed []*pb.PathElem
 	for _, e := range elms {
 		n, keys, err := parseElement(e)
@@ -158,11 +192,30 @@ func ParseGNMIElements(elms []string) (*pb.Path, error) {
 		parsed = append(parsed, &pb.PathElem{Name: n, Key: keys})
 	}
 	return &pb.Path{
+		Origin:  origin,
 		Element: elms, // Backwards compatibility with pre-v0.4 gnmi
 		Elem:    parsed,
 	}, nil
 }
 
+// parseOrigin returns the origin named by a path element of the form
+// "origin:/", and whether the element is an origin.
+func parseOrigin(elm string) (string, bool) {
+	if !strings.HasSuffix(elm, "/") || !isOrigin(elm[:len(elm)-1]) {
+		return "", false
+	}
+	return elm[:len(elm)-2], true
+}
+
+// isOrigin returns whether tok, the text before the first slash of a
+// path, is an origin followed by a colon.
+func isOrigin(tok string) bool {
+	if len(tok) < 2 || tok[len(tok)-1] != ':' {
+		return false
+	}
+	return !strings.ContainsAny(tok[:len(tok)-1], `:[]=\/`)
+}
+
 // parseElement parses a path element, according to the gNMI specification. See
 // https://github.com/openconfig/reference/blame/master/rpc/gnmi/gnmi-path-conventions.md
 //
@@ -48,6 +48,21 @@ func TestSplitPath(t *testing.T) {
 	}, {
 		in:  "/foo[a=1][b=2]/bar\\baz",
 		exp: p("foo[a=1][b=2]", "bar\\baz"),
+	}, {
+		in:  "eos_native:/Sysdb/foo",
+		exp: p("eos_native:/", "Sysdb", "foo"),
+	}, {
+		in:  "openconfig-interfaces:/interfaces/interface[name=Ethernet1:1]",
+		exp: p("openconfig-interfaces:/", "interfaces", "interface[name=Ethernet1:1]"),
+	}, {
+		in:  "cli:",
+		exp: p("cli:/"),
+	}, {
+		in:  "/a:/b",
+		exp: p("a:", "b"),
+	}, {
+		in:  "foo/bar:",
+		exp: p("foo", "bar:"),
 	}} {
 		got := SplitPath(tc.in)
 		if !test.DeepEqual(tc.exp, got) {
@@ -72,13 +87,19 @@ func TestStrPath(t *testing.T) {
 		path: "/foo[a=1\\]2][b=2]/bar",
 	}, {
 		path: "/foo[a=1][b=2]/bar\\/baz",
+	}, {
+		path: "eos_native:/Sysdb/foo",
+	}, {
+		path: "openconfig:/foo[name=a:b]/bar",
+	}, {
+		path: "cli:/",
 	}} {
 		sElms := SplitPath(tc.path)
 		pbPath, err := ParseGNMIElements(sElms)
 		if err != nil {
 			t.Errorf("failed to parse %s: %s", sElms, err)
 		}
-		s := StrPath(pbPath)
+		s := StrPathWithOrigin(pbPath)
 		if !test.DeepEqual(tc.path, s) {
 			t.Errorf("[%d] want %s, got %s", i, tc.path, s)
 		}
@@ -102,6 +123,83 @@ func TestStrPathBackwardsCompat(t *testing.T) {
 	}
 }
 
+func TestParseGNMIElementsOrigin(t *testing.T) {
+	for name, tc := range map[string]struct {
+		elms   []string
+		origin string
+		elems  []string
+	}{
+		"no_origin": {
+			elms:  p("foo", "bar"),
+			elems: p("foo", "bar"),
+		},
+		"origin": {
+			elms:   p("eos_native:/", "Sysdb", "foo"),
+			origin: "eos_native",
+			elems:  p("Sysdb", "foo"),
+		},
+		"origin_only": {
+			elms:   p("cli:/"),
+			origin: "cli",
+		},
+		"module_prefix": {
+			elms:  p("openconfig-interfaces:interfaces", "interface"),
+			elems: p("openconfig-interfaces:interfaces", "interface"),
+		},
+		"not_first": {
+			elms:  p("foo", "bar:/"),
+			elems: p("foo", "bar:/"),
+		},
+		"element_with_colon": {
+			elms:  p("a:", "b"),
+			elems: p("a:", "b"),
+		},
+		"slash_before_origin": {
+			elms:  SplitPath("/a:/b"),
+			elems: p("a:", "b"),
+		},
+		"key": {
+			elms:  p("foo[a=b:]"),
+			elems: p("foo"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path, err := ParseGNMIElements(tc.elms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path.Origin != tc.origin {
+				t.Errorf("expected origin %q, got %q", tc.origin, path.Origin)
+			}
+			var elems []string
+			for _, e := range path.Elem {
+				elems = append(elems, e.Name)
+			}
+			if !test.DeepEqual(tc.elems, elems) {
+				t.Errorf("expected elems %v, got %v", tc.elems, elems)
+			}
+			if len(path.Element) != len(path.Elem) {
+				t.Errorf("expected Element %v to match Elem", path.Element)
+			}
+		})
+	}
+}
+
+func TestStrPathWithOrigin(t *testing.T) {
+	path := &pb.Path{Origin: "openconfig", Elem: []*pb.PathElem{{Name: "foo"}}}
+	if s := StrPathWithOrigin(path); s != "openconfig:/foo" {
+		t.Errorf("expected openconfig:/foo, got %q", s)
+	}
+	// StrPath leaves the origin out, so that its callers can join the
+	// path of an update to its prefix.
+	if s := StrPath(path); s != "/foo" {
+		t.Errorf("expected /foo, got %q", s)
+	}
+	if s := StrPathWithOrigin(&pb.Path{Elem: path.Elem}); s != "/foo" {
+		t.Errorf("expected /foo, got %q", s)
+	}
+}
+
 func TestParseElement(t *testing.T) {
 	// test cases
 	cases := []struct {
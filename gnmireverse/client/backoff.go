@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aristanetworks/glog"
+	"google.golang.org/grpc"
+	grpcbackoff "google.golang.org/grpc/backoff"
+)
+
+// backoffMultiplier is the factor the retry delay grows by after
+// each consecutive failure.
+const backoffMultiplier = 2
+
+// backoffPolicy describes how long to wait before retrying after a
+// failure.
+type backoffPolicy struct {
+	// initial is the delay after the first failure.
+	initial time.Duration
+	// max is the upper bound of the delay.
+	max time.Duration
+	// jitter is the fraction by which the delay is randomized,
+	// e.g. 0.2 results in a delay within +/-20% of the nominal
+	// value.
+	jitter float64
+	// resetAfter is how long an attempt must run without error for
+	// the delay to return to initial.
+	resetAfter time.Duration
+}
+
+func (p *backoffPolicy) validate() error {
+	if p.initial <= 0 {
+		return fmt.Errorf("initial backoff must be positive, got %s", p.initial)
+	}
+	if p.max < p.initial {
+		return fmt.Errorf("max backoff (%s) must not be less than initial backoff (%s)",
+			p.max, p.initial)
+	}
+	if p.jitter < 0 || p.jitter > 1 {
+		return fmt.Errorf("backoff jitter must be in the range 0-1, got %g", p.jitter)
+	}
+	if p.resetAfter <= 0 {
+		return fmt.Errorf("backoff reset must be positive, got %s", p.resetAfter)
+	}
+	return nil
+}
+
+// dialOption returns a grpc.DialOption that makes gRPC follow p
+// when reconnecting the underlying transport.
+func (p *backoffPolicy) dialOption() grpc.DialOption {
+	return grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff: grpcbackoff.Config{
+			BaseDelay:  p.initial,
+			Multiplier: backoffMultiplier,
+			Jitter:     p.jitter,
+			MaxDelay:   p.max,
+		},
+		MinConnectTimeout: 20 * time.Second,
+	})
+}
+
+// backoff tracks the retry delay of one reconnect loop.
+type backoff struct {
+	policy *backoffPolicy
+	delay  time.Duration
+	// start is when the current attempt started.
+	start time.Time
+}
+
+func newBackoff(policy *backoffPolicy) *backoff {
+	return &backoff{policy: policy}
+}
+
+// started records that a new attempt has begun.
+func (b *backoff) started() {
+	b.start = time.Now()
+}
+
+// next returns how long to wait before the next attempt. If the
+// attempt that just finished lasted at least resetAfter, the delay is
+// reset to the initial delay.
+func (b *backoff) next() time.Duration {
+	if b.delay == 0 || (!b.start.IsZero() && time.Since(b.start) >= b.policy.resetAfter) {
+		b.delay = b.policy.initial
+	} else {
+		b.delay *= backoffMultiplier
+		if b.delay > b.policy.max {
+			b.delay = b.policy.max
+		}
+	}
+	d := b.delay
+	if j := b.policy.jitter; j > 0 {
+		d = time.Duration(float64(d) * (1 + j*(rand.Float64()*2-1)))
+	}
+	return d
+}
+
+// rateLimitedLogger logs at most one error per interval. Errors that
+// are not logged are counted and reported with the next logged one,
+// so a flapping connection doesn't flood the log.
+type rateLimitedLogger struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+func (l *rateLimitedLogger) Errorf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if !l.last.IsZero() && now.Sub(l.last) < l.interval {
+		l.suppressed++
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if l.suppressed > 0 {
+		msg = fmt.Sprintf("%s (%d similar errors suppressed)", msg, l.suppressed)
+	}
+	glog.ErrorDepth(1, msg)
+	l.last = now
+	l.suppressed = 0
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := &backoffPolicy{
+		initial:    time.Second,
+		max:        5 * time.Second,
+		resetAfter: time.Minute,
+	}
+	if err := policy.validate(); err != nil {
+		t.Fatal(err)
+	}
+	b := newBackoff(policy)
+	b.started()
+	for i, exp := range []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	} {
+		if d := b.next(); d != exp {
+			t.Errorf("[%d] expected delay %s, got %s", i, exp, d)
+		}
+	}
+
+	// An attempt that lasted longer than resetAfter resets the delay
+	b.start = time.Now().Add(-2 * time.Minute)
+	if d := b.next(); d != time.Second {
+		t.Errorf("expected delay to be reset to %s, got %s", time.Second, d)
+	}
+
+	policy.jitter = 0.5
+	for i := 0; i < 100; i++ {
+		b := newBackoff(policy)
+		if d := b.next(); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay with jitter out of range: %s", d)
+		}
+	}
+}
+
+func TestBackoffPolicyValidate(t *testing.T) {
+	for name, p := range map[string]backoffPolicy{
+		"zero_initial":   {max: time.Second},
+		"max_lt_initial": {initial: time.Second, max: time.Millisecond},
+		"large_jitter":   {initial: time.Second, max: time.Second, jitter: 1.5},
+		"zero_reset":     {initial: time.Second, max: time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := p.validate(); err == nil {
+				t.Error("expected error and didn't get one")
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+)
+
+// Delivery modes used when publishing to multiple collectors.
+const (
+	// deliverAll publishes every response to every collector. Each
+	// collector has its own queue, fed from the subscriber's queue.
+	deliverAll = "all"
+	// deliverAny publishes every response to the first reachable
+	// collector, in the order they are given. The next collector is
+	// used only when the current one fails.
+	deliverAny = "any"
+)
+
+// collector is a destination of the Publish stream, with its own
+// connection, queue and spool.
+type collector struct {
+	cfg     *collectorConfig
+	conn    *grpc.ClientConn
+	dialer  *vrfDialer
+	q       *queue
+	sp      *spool
+	metrics *collectorMetrics
+	// failover is set when the collector is used in turn with other
+	// collectors: opening its Publish stream fails instead of waiting
+	// for the collector to be reachable.
+	failover bool
+	// heartbeatPath is the path of the heartbeats published to the
+	// collector, if any.
+	heartbeatPath *gnmi.Path
+}
+
+// collectorQueues are the queues of the collectors when each response
+// is published to each collector, by collector address. They are kept
+// across config reloads, like the collector metrics, so that the
+// responses still queued for a collector aren't lost. A queue whose
+// size or policy changed is replaced, see collectorQueue.
+var (
+	collectorQueuesMu sync.Mutex
+	collectorQueues   = map[string]*queue{}
+)
+
+// collectorQueue returns the queue of the collector at addr, creating
+// it if needed. If the queue settings of cfg differ from those of the
+// existing queue, the existing queue is replaced by a new one holding
+// its responses, the oldest ones being discarded if they don't fit.
+// It must not be called while the existing queue is in use.
+func collectorQueue(cfg *config, addr string) (*queue, error) {
+	collectorQueuesMu.Lock()
+	defer collectorQueuesMu.Unlock()
+	old, ok := collectorQueues[addr]
+	if ok && old.size == cfg.queueSize && old.policy == cfg.queuePolicy {
+		return old, nil
+	}
+	q, err := newQueue(cfg.queueSize, cfg.queuePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		resps := old.take()
+		if n := len(resps) - q.size; n > 0 {
+			atomic.AddUint64(&q.dropped, uint64(n))
+			resps = resps[n:]
+		}
+		for _, resp := range resps {
+			if err := q.Put(context.Background(), resp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	collectorQueues[addr] = q
+	return q, nil
+}
+
+// pruneCollectorQueues discards the queues of the collectors that
+// aren't in collectors anymore.
+func pruneCollectorQueues(collectors []*collector) {
+	collectorQueuesMu.Lock()
+	defer collectorQueuesMu.Unlock()
+	for addr, q := range collectorQueues {
+		kept := false
+		for _, c := range collectors {
+			if c.q == q {
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			delete(collectorQueues, addr)
+		}
+	}
+}
+
+// newCollectors dials each of the collectors in cfg. If responses
+// must be published to each collector, each collector is given its own
+// queue, see collectorQueue, otherwise they all use q.
+func newCollectors(cfg *config, q *queue) ([]*collector, error) {
+	var collectors []*collector
+	for _, ccfg := range cfg.collectors {
+		c, err := newCollector(cfg, ccfg, q)
+		if err != nil {
+			for _, c := range collectors {
+				c.close()
+			}
+			return nil, err
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
+
+func newCollector(cfg *config, ccfg *collectorConfig, q *queue) (*collector, error) {
+	var err error
+	c := &collector{cfg: ccfg, q: q}
+	if cfg.heartbeatInterval > 0 {
+		c.heartbeatPath = cfg.heartbeatPath
+	}
+	if len(cfg.collectors) > 1 {
+		switch cfg.deliveryMode {
+		case deliverAll:
+			c.q, err = collectorQueue(cfg, ccfg.addr)
+			if err != nil {
+				return nil, err
+			}
+		case deliverAny:
+			c.failover = true
+		}
+	}
+	if cfg.spoolDir != "" {
+		dir := filepath.Join(cfg.spoolDir, url.PathEscape(ccfg.addr))
+		c.sp, err = newSpool(dir, cfg.spoolMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error opening spool for collector %q: %s", ccfg.addr, err)
+		}
+	}
+	c.conn, c.dialer, err = dialCollector(ccfg, &cfg.backoff, &cfg.transport)
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("error dialing collector %q: %s", ccfg.addr, err)
+	}
+	c.metrics = newCollectorMetrics(c)
+	return c, nil
+}
+
+// close closes the connection to the collector and its spool.
+func (c *collector) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.dialer.close()
+	}
+	if c.sp != nil {
+		if err := c.sp.close(); err != nil {
+			collectorLog.Errorf("error closing spool for collector %q: %s", c.cfg.addr, err)
+		}
+	}
+}
+
+// runCollectors publishes the responses from q to the collectors
+// until ctx is done.
+func runCollectors(ctx context.Context, cfg *config, q *queue, collectors []*collector) {
+	if len(collectors) > 1 && collectors[0].failover {
+		runFailover(ctx, cfg, collectors)
+		return
+	}
+	var wg sync.WaitGroup
+	var queues []*queue
+	for _, c := range collectors {
+		if c.q != q {
+			queues = append(queues, c.q)
+			go c.q.logDrops(ctx, "collector "+c.cfg.addr, cfg.errorLogInterval)
+		}
+		wg.Add(1)
+		go func(c *collector) {
+			defer wg.Done()
+			c.run(ctx, cfg)
+		}(c)
+	}
+	if len(queues) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fanOut(ctx, q, queues)
+		}()
+	}
+	wg.Wait()
+}
+
+// fanOut copies each response from q to every queue in queues, until
+// ctx is done. A response taken from q when ctx is done is still added
+// to the queues that have room for it, the others count it as dropped.
+func fanOut(ctx context.Context, q *queue, queues []*queue) {
+	for {
+		resp, err := q.Get(ctx)
+		if err != nil {
+			return
+		}
+		for _, cq := range queues {
+			// Put only fails when ctx is done and cq is full
+			if err := cq.Put(ctx, resp); err != nil {
+				atomic.AddUint64(&cq.dropped, 1)
+			}
+		}
+	}
+}
+
+// runFailover publishes the responses from q to the first of the
+// collectors whose Publish stream can be opened, moving to the next
+// collector when it fails, until ctx is done. Once all the collectors
+// failed, it starts over from the first one after the backoff delay.
+func runFailover(ctx context.Context, cfg *config, collectors []*collector) {
+	retry := newBackoff(&cfg.backoff)
+	errLog := &rateLimitedLogger{interval: cfg.errorLogInterval}
+	for {
+		retry.started()
+		for _, c := range collectors {
+			err := publish(ctx, c)
+			if ctx.Err() != nil {
+				return
+			}
+			c.metrics.reconnects.Add(1)
+			errLog.Errorf("encountered error with collector %s, "+
+				"failing over to the next collector: %s", c.cfg.addr, err)
+		}
+		delay := retry.next()
+		errLog.Errorf("no collector is reachable, retrying in %s", delay)
+		sleep(ctx, delay)
+	}
+}
+
+// run publishes the responses from the collector's queue until ctx is
+// done.
+func (c *collector) run(ctx context.Context, cfg *config) {
+	wait := sleep
+	if c.sp != nil {
+		// Keep spooling while waiting to reconnect to the collector.
+		wait = func(ctx context.Context, d time.Duration) {
+			c.sp.fillWhile(c.q, func() error {
+				sleep(ctx, d)
+				return nil
+			})
+		}
+	}
+	retryLoop(ctx, "collector "+c.cfg.addr, cfg, c.metrics.reconnects,
+		func(ctx context.Context) error {
+			return publish(ctx, c)
+		}, wait)
+}
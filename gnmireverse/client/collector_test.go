@@ -0,0 +1,231 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/goarista/gnmireverse"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+)
+
+func TestCollectorQueuesReload(t *testing.T) {
+	// Discard the queues left by the previous runs
+	pruneCollectorQueues(nil)
+	defer pruneCollectorQueues(nil)
+	q, err := newQueue(10, queueDropOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{
+		deliveryMode: deliverAll,
+		queueSize:    10,
+		queuePolicy:  queueDropOldest,
+		collectors: []*collectorConfig{
+			{addr: "127.0.0.1:1"},
+			{addr: "127.0.0.1:2"},
+		},
+	}
+	start := func(cfg *config) []*collector {
+		t.Helper()
+		collectors, err := newCollectors(cfg, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range collectors {
+			c.close()
+		}
+		pruneCollectorQueues(collectors)
+		return collectors
+	}
+
+	collectors := start(cfg)
+	if collectors[0].q == q || collectors[0].q == collectors[1].q {
+		t.Fatal("expected a queue per collector")
+	}
+	for _, c := range collectors {
+		if err := c.q.Put(context.Background(), notification(1, "a")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The queued responses are kept after a reload
+	reloaded := start(cfg)
+	for i, c := range reloaded {
+		if c.q != collectors[i].q || c.q.Len() != 1 {
+			t.Errorf("expected the queue of collector %s to be kept", c.cfg.addr)
+		}
+	}
+
+	// The queue of a collector that is removed is discarded
+	start(&config{
+		deliveryMode: deliverAll,
+		queueSize:    10,
+		queuePolicy:  queueDropOldest,
+		collectors:   []*collectorConfig{cfg.collectors[0], {addr: "127.0.0.1:3"}},
+	})
+	reloaded = start(cfg)
+	if reloaded[0].q != collectors[0].q {
+		t.Error("expected the queue of the first collector to be kept")
+	}
+	if reloaded[1].q == collectors[1].q || reloaded[1].q.Len() != 0 {
+		t.Error("expected a new queue for the collector that was removed")
+	}
+
+	// A queue whose size changed is replaced, keeping the newest
+	// responses that fit in it
+	for i := int64(2); i <= 3; i++ {
+		if err := reloaded[0].q.Put(context.Background(), notification(i, "b")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resized := *cfg
+	resized.queueSize = 2
+	collectors = start(&resized)
+	if collectors[0].q == reloaded[0].q || collectors[0].q.size != 2 {
+		t.Fatal("expected a new queue for the collector whose queue size changed")
+	}
+	if d := collectors[0].q.Dropped(); d != 1 {
+		t.Errorf("expected 1 response dropped, got %d", d)
+	}
+	resps := drain(t, collectors[0].q)
+	if len(resps) != 2 || resps[0].GetUpdate().GetTimestamp() != 2 ||
+		resps[1].GetUpdate().GetTimestamp() != 3 {
+		t.Errorf("expected the responses at 2 and 3, got %v", resps)
+	}
+}
+
+func TestFanOutCanceled(t *testing.T) {
+	q, err := newQueue(10, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := newQueue(1, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newQueue(1, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, resp := range []*gnmi.SubscribeResponse{notification(1, "a")} {
+		if err := full.Put(context.Background(), resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Put(context.Background(), notification(2, "a")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The full queue comes first: the response must still reach the
+	// queue after it.
+	fanOut(ctx, q, []*queue{full, other})
+	if other.Len() != 1 {
+		t.Errorf("expected the response in the queue with room, got %d responses",
+			other.Len())
+	}
+	if full.Dropped() != 1 {
+		t.Errorf("expected the response to be dropped by the full queue, got %d drops",
+			full.Dropped())
+	}
+}
+
+// startFakeCollector starts a fakeCollector and returns its address.
+func startFakeCollector(t *testing.T) (string, *fakeCollector, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeCollector{responses: make(chan *gnmi.SubscribeResponse, 10)}
+	s := grpc.NewServer()
+	gnmireverse.RegisterGNMIReverseServer(s, fc)
+	go s.Serve(l)
+	return l.Addr().String(), fc, s.Stop
+}
+
+func TestFailover(t *testing.T) {
+	// Nothing listens on the address of the primary collector
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := l.Addr().String()
+	l.Close()
+	backupAddr, backup, stopBackup := startFakeCollector(t)
+	defer stopBackup()
+	thirdAddr, third, stopThird := startFakeCollector(t)
+	defer stopThird()
+
+	q, err := newQueue(10, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{
+		deliveryMode: deliverAny,
+		queueSize:    10,
+		queuePolicy:  queueBlock,
+		collectors: []*collectorConfig{
+			{addr: down}, {addr: backupAddr}, {addr: thirdAddr},
+		},
+		backoff: backoffPolicy{
+			initial:    10 * time.Millisecond,
+			max:        100 * time.Millisecond,
+			resetAfter: time.Minute,
+		},
+	}
+	collectors, err := newCollectors(cfg, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, c := range collectors {
+			c.close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go runCollectors(ctx, cfg, q, collectors)
+
+	// Every response goes to the first reachable collector
+	for i := int64(1); i <= 3; i++ {
+		if err := q.Put(ctx, notification(i, "a")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case resp := <-backup.responses:
+			if ts := resp.GetUpdate().GetTimestamp(); ts != i {
+				t.Fatalf("expected timestamp %d, got %d", i, ts)
+			}
+		case <-ctx.Done():
+			t.Fatal("response not published to the backup collector")
+		}
+	}
+
+	// The next collector is used once the current one fails
+	stopBackup()
+	for collectors[1].metrics.streamUp.Value() != 0 {
+		if ctx.Err() != nil {
+			t.Fatal("the stream to the backup collector didn't fail")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Put(ctx, notification(4, "a")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-third.responses:
+		if ts := resp.GetUpdate().GetTimestamp(); ts != 4 {
+			t.Fatalf("expected timestamp 4, got %d", ts)
+		}
+	case <-ctx.Done():
+		t.Fatal("response not published to the third collector")
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/aristanetworks/goarista/gnmireverse/transform"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"gopkg.in/yaml.v2"
+)
+
+// fileConfig is the representation of the config file given with
+// -config_file. Since JSON is a subset of YAML, the file may be
+// written in either.
+type fileConfig struct {
+	Target        fileTarget         `yaml:"target"`
+	Subscriptions []fileSubscription `yaml:"subscriptions"`
+	Collectors    []fileCollector    `yaml:"collectors"`
+	DeliveryMode  string             `yaml:"delivery_mode"`
+	// Transforms are applied in order to the responses received from
+	// the target before they are published.
+	Transforms []transform.Config `yaml:"transforms"`
+}
+
+// fileTarget holds the settings of the -target_addr, -username,
+// -password, -target_value, -origin and -history_replay flags.
+type fileTarget struct {
+	Addr          string `yaml:"addr"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	Value         string `yaml:"target_value"`
+	Origin        string `yaml:"origin"`
+	HistoryReplay *bool  `yaml:"history_replay"`
+}
+
+// fileSubscription is the equivalent of a -subscribe flag, or of a
+// -sample flag if Mode is "sample".
+type fileSubscription struct {
+	Path string `yaml:"path"`
+	// Mode is "target_defined" (the default) or "sample".
+	Mode string `yaml:"mode"`
+	// Interval is the heartbeat interval of a TARGET_DEFINED
+	// subscription, or the sample interval of a SAMPLE subscription.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// fileCollector holds the settings of one collector. Settings left
+// unset default to the value of the corresponding flag.
+type fileCollector struct {
+	Addr          string `yaml:"addr"`
+	SourceAddr    string `yaml:"source_addr"`
+	DSCP          *int   `yaml:"dscp"`
+	TLS           *bool  `yaml:"tls"`
+	TLSSkipVerify *bool  `yaml:"tls_skipverify"`
+	CertFile      string `yaml:"certfile"`
+	KeyFile       string `yaml:"keyfile"`
+	CAFile        string `yaml:"cafile"`
+
+	CredentialsFile string `yaml:"credentials_file"`
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+}
+
+// loadConfig returns the config to run with: the settings of flagCfg,
+// overridden by those of flagCfg.configFile if set.
+func loadConfig(flagCfg *config) (*config, error) {
+	cfg := *flagCfg
+	cfg.collectors = nil
+	if cfg.configFile != "" {
+		b, err := ioutil.ReadFile(cfg.configFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.apply(b); err != nil {
+			return nil, fmt.Errorf("error in config file %s: %s", cfg.configFile, err)
+		}
+	}
+	if len(cfg.collectors) == 0 {
+		for _, addr := range cfg.collectorAddrs {
+			c := cfg.collectorFlags
+			c.addr = addr
+			cfg.collectors = append(cfg.collectors, &c)
+		}
+	}
+	if len(cfg.collectors) == 0 {
+		return nil, fmt.Errorf("at least one collector address must be set")
+	}
+	for _, c := range cfg.collectors {
+		if c.hasCredentials() && !c.tls {
+			return nil, fmt.Errorf("credentials for collector %q require TLS", c.addr)
+		}
+	}
+	switch cfg.deliveryMode {
+	case deliverAll:
+	case deliverAny:
+		if cfg.spoolDir != "" && len(cfg.collectors) > 1 {
+			return nil, fmt.Errorf("spooling is not supported with multiple collectors "+
+				"in %q delivery mode", deliverAny)
+		}
+	default:
+		return nil, fmt.Errorf("unknown delivery mode: %q", cfg.deliveryMode)
+	}
+
+	if cfg.origin != "" {
+		// Workaround for EOS BUG479731: set origin on paths, rather
+		// than on the prefix.
+		cfg.subTargetDefined.subs = withOrigin(cfg.subTargetDefined.subs, cfg.origin)
+		cfg.subSample.subs = withOrigin(cfg.subSample.subs, cfg.origin)
+	}
+	return &cfg, nil
+}
+
+// withOrigin returns a copy of subs with origin set on the paths that
+// don't specify their own origin.
+func withOrigin(subs []subscription, origin string) []subscription {
+	out := make([]subscription, len(subs))
+	for i, sub := range subs {
+		p := sub.p
+		if p.Origin == "" {
+			p = proto.Clone(sub.p).(*gnmi.Path)
+			p.Origin = origin
+		}
+		out[i] = subscription{p: p, interval: sub.interval}
+	}
+	return out
+}
+
+// apply overrides the settings of cfg with those of the config file
+// contents b.
+func (cfg *config) apply(b []byte) error {
+	var f fileConfig
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return err
+	}
+
+	if f.Target.Addr != "" {
+		cfg.targetAddr = f.Target.Addr
+	}
+	if f.Target.Username != "" {
+		cfg.username = f.Target.Username
+		cfg.password = f.Target.Password
+	}
+	if f.Target.Value != "" {
+		cfg.targetVal = f.Target.Value
+	}
+	if f.Target.Origin != "" {
+		cfg.origin = f.Target.Origin
+	}
+	if f.Target.HistoryReplay != nil {
+		cfg.historyReplay = *f.Target.HistoryReplay
+	}
+
+	if len(f.Subscriptions) > 0 {
+		cfg.subTargetDefined = subscriptionList{}
+		cfg.subSample = sampleList{}
+		for _, sub := range f.Subscriptions {
+			if sub.Path == "" {
+				return fmt.Errorf("subscription is missing a path")
+			}
+			if sub.Interval < 0 {
+				return fmt.Errorf("negative interval not allowed: %q", sub.Path)
+			}
+			var err error
+			switch sub.Mode {
+			case "", "target_defined":
+				err = setSubscriptions(&cfg.subTargetDefined.subs, sub.Path, sub.Interval)
+			case "sample":
+				if sub.Interval == 0 {
+					return fmt.Errorf("SAMPLE subscription is missing interval: %q", sub.Path)
+				}
+				err = setSubscriptions(&cfg.subSample.subs, sub.Path, sub.Interval)
+			default:
+				return fmt.Errorf("unknown subscription mode %q for %q", sub.Mode, sub.Path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, fc := range f.Collectors {
+		if fc.Addr == "" {
+			return fmt.Errorf("collector is missing an address")
+		}
+		c := cfg.collectorFlags
+		c.addr = fc.Addr
+		if fc.SourceAddr != "" {
+			c.sourceAddr = fc.SourceAddr
+		}
+		if fc.DSCP != nil {
+			c.dscp = *fc.DSCP
+		}
+		if fc.TLS != nil {
+			c.tls = *fc.TLS
+		}
+		if fc.TLSSkipVerify != nil {
+			c.skipVerify = *fc.TLSSkipVerify
+		}
+		if fc.CertFile != "" {
+			c.cert = fc.CertFile
+		}
+		if fc.KeyFile != "" {
+			c.key = fc.KeyFile
+		}
+		if fc.CAFile != "" {
+			c.ca = fc.CAFile
+		}
+		if fc.CredentialsFile != "" {
+			c.credentialsFile = fc.CredentialsFile
+		}
+		if fc.Username != "" {
+			c.username = fc.Username
+			c.password = fc.Password
+		}
+		cfg.collectors = append(cfg.collectors, &c)
+	}
+
+	if f.DeliveryMode != "" {
+		cfg.deliveryMode = f.DeliveryMode
+	}
+
+	transforms, err := transform.New(f.Transforms)
+	if err != nil {
+		return err
+	}
+	cfg.transforms = transforms
+	return nil
+}
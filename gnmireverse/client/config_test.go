@@ -0,0 +1,290 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	gnmilib "github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/test"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "gnmireverse_client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestLoadConfig(t *testing.T) {
+	flagCfg := config{
+		targetAddr:     "127.0.0.1:6030",
+		username:       "flaguser",
+		password:       "flagpass",
+		collectorAddrs: []string{"1.1.1.1:6000"},
+		collectorFlags: collectorConfig{tls: true, dscp: 8},
+		deliveryMode:   deliverAll,
+	}
+	if err := flagCfg.subTargetDefined.Set("/from/flag"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		file string
+
+		error      bool
+		targetAddr string
+		username   string
+		origin     string
+		subs       []string
+		samples    []string
+		collectors []*collectorConfig
+	}{
+		"no_file": {
+			targetAddr: "127.0.0.1:6030",
+			username:   "flaguser",
+			subs:       []string{"/from/flag"},
+			collectors: []*collectorConfig{{addr: "1.1.1.1:6000", tls: true, dscp: 8}},
+		},
+		"yaml": {
+			file: `
+target:
+  addr: mgmt/127.0.0.1:6030
+  username: admin
+  password: secret
+  origin: openconfig
+subscriptions:
+  - path: /network-instances
+  - path: /interfaces/interface/state/counters
+    mode: sample
+    interval: 30s
+collectors:
+  - addr: mgmt/10.0.0.1:6000
+    tls: false
+  - addr: mgmt/10.0.0.2:6000
+    dscp: 48
+    cafile: ca.pem
+`,
+			targetAddr: "mgmt/127.0.0.1:6030",
+			username:   "admin",
+			origin:     "openconfig",
+			subs:       []string{"openconfig:/network-instances"},
+			samples:    []string{"openconfig:/interfaces/interface/state/counters@30s"},
+			collectors: []*collectorConfig{
+				{addr: "mgmt/10.0.0.1:6000", tls: false, dscp: 8},
+				{addr: "mgmt/10.0.0.2:6000", tls: true, dscp: 48, ca: "ca.pem"},
+			},
+		},
+		"json": {
+			file:       `{"target": {"target_value": "dev1"}, "delivery_mode": "any"}`,
+			targetAddr: "127.0.0.1:6030",
+			username:   "flaguser",
+			subs:       []string{"/from/flag"},
+			collectors: []*collectorConfig{{addr: "1.1.1.1:6000", tls: true, dscp: 8}},
+		},
+		"unknown_field": {
+			file:  "target:\n  adress: 127.0.0.1:6030\n",
+			error: true,
+		},
+		"sample_without_interval": {
+			file:  "subscriptions:\n  - path: /a\n    mode: sample\n",
+			error: true,
+		},
+		"credentials": {
+			file: `
+collectors:
+  - addr: 10.0.0.1:6000
+    credentials_file: /etc/token
+  - addr: 10.0.0.2:6000
+    username: user
+    password: pass
+`,
+			targetAddr: "127.0.0.1:6030",
+			username:   "flaguser",
+			subs:       []string{"/from/flag"},
+			collectors: []*collectorConfig{
+				{addr: "10.0.0.1:6000", tls: true, dscp: 8, credentialsFile: "/etc/token"},
+				{addr: "10.0.0.2:6000", tls: true, dscp: 8, username: "user", password: "pass"},
+			},
+		},
+		"credentials_without_tls": {
+			file:  "collectors:\n  - addr: 10.0.0.1:6000\n    tls: false\n    username: user\n",
+			error: true,
+		},
+		"bad_delivery_mode": {
+			file:  "delivery_mode: some\n",
+			error: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fc := flagCfg
+			if tc.file != "" {
+				fc.configFile = writeConfigFile(t, tc.file)
+				defer os.Remove(fc.configFile)
+			}
+			cfg, err := loadConfig(&fc)
+			if err != nil {
+				if !tc.error {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			} else if tc.error {
+				t.Fatal("expected error and didn't get one")
+			}
+			if cfg.targetAddr != tc.targetAddr {
+				t.Errorf("expected target address %q, got %q", tc.targetAddr, cfg.targetAddr)
+			}
+			if cfg.username != tc.username {
+				t.Errorf("expected username %q, got %q", tc.username, cfg.username)
+			}
+			if s := str(cfg.subTargetDefined.subs); s != str(parseSubs(t, tc.subs)) {
+				t.Errorf("unexpected subscriptions: %s", s)
+			}
+			if s := str(cfg.subSample.subs); s != str(parseSubs(t, tc.samples)) {
+				t.Errorf("unexpected samples: %s", s)
+			}
+			for _, sub := range append(cfg.subTargetDefined.subs, cfg.subSample.subs...) {
+				if sub.p.Origin != tc.origin {
+					t.Errorf("expected origin %q on %s, got %q",
+						tc.origin, gnmilib.StrPathWithOrigin(sub.p), sub.p.Origin)
+				}
+			}
+			if !test.DeepEqual(tc.collectors, cfg.collectors) {
+				t.Errorf("unexpected collectors: %s", test.Diff(tc.collectors, cfg.collectors))
+			}
+		})
+	}
+	if flagCfg.subTargetDefined.subs[0].p.Origin != "" {
+		t.Error("loadConfig modified the paths of the flag config")
+	}
+}
+
+func parseSubs(t *testing.T, subs []string) []subscription {
+	var l subscriptionList
+	for _, s := range subs {
+		if err := l.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l.subs
+}
+
+func TestConfigSubscriptionInterval(t *testing.T) {
+	var cfg config
+	err := cfg.apply([]byte("subscriptions:\n  - path: /a\n    interval: 1m30s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := cfg.subTargetDefined.subs[0].interval; i != 90*time.Second {
+		t.Errorf("expected interval of 1m30s, got %s", i)
+	}
+}
+
+func TestConfigMixedOrigin(t *testing.T) {
+	flagCfg := config{
+		collectorAddrs: []string{"1.1.1.1:6000"},
+		deliveryMode:   deliverAll,
+		origin:         "openconfig",
+	}
+	for _, s := range []string{"/interfaces", "eos_native:/Sysdb/hardware"} {
+		if err := flagCfg.subTargetDefined.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := loadConfig(&flagCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "openconfig:/interfaces, eos_native:/Sysdb/hardware"
+	if s := str(cfg.subTargetDefined.subs); s != expected {
+		t.Errorf("expected subscriptions %q, got %q", expected, s)
+	}
+}
+
+func TestConfigTransforms(t *testing.T) {
+	var cfg config
+	err := cfg.apply([]byte(`
+transforms:
+  - type: drop
+    prefix: [/system/processes]
+  - type: set_target
+    target: dev1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.transforms) != 2 {
+		t.Errorf("expected 2 transforms, got %d", len(cfg.transforms))
+	}
+	if err := cfg.apply([]byte("transforms:\n  - type: drop\n")); err == nil {
+		t.Error("expected error for drop transform without paths")
+	}
+}
+
+func TestRunConfigs(t *testing.T) {
+	a, b, bad := &config{targetAddr: "a"}, &config{targetAddr: "b"}, &config{targetAddr: "bad"}
+	reloads := make(chan *config)
+	runs := make(chan string, 10)
+	bRuns := 0
+	run := func(ctx context.Context, cfg *config) error {
+		runs <- cfg.targetAddr
+		switch cfg {
+		case bad:
+			return errors.New("bad config")
+		case b:
+			if bRuns++; bRuns == 2 {
+				return errors.New("b failed")
+			}
+		}
+		<-ctx.Done()
+		return nil
+	}
+	errc := make(chan error)
+	go func() {
+		errc <- runConfigs(a, reloads, run)
+	}()
+	expectRuns := func(expected ...string) {
+		t.Helper()
+		for _, name := range expected {
+			select {
+			case run := <-runs:
+				if run != name {
+					t.Fatalf("expected a run with %s, got %s", name, run)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("expected a run with %s", name)
+			}
+		}
+	}
+
+	expectRuns("a")
+	// A reloaded config that fails goes back to the previous config
+	reloads <- bad
+	expectRuns("bad", "a")
+	reloads <- b
+	expectRuns("b")
+	reloads <- bad
+	expectRuns("bad", "b")
+	// The previous config isn't a reloaded config anymore once it is
+	// gone back to, so its error is returned.
+	select {
+	case err := <-errc:
+		if err == nil || err.Error() != "b failed" {
+			t.Errorf("expected the error of b, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("runConfigs didn't return")
+	}
+}
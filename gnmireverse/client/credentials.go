@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// rpcCredentials implements credentials.PerRPCCredentials, the gRPC
+// interface for credentials that need to attach security information
+// to every RPC.
+type rpcCredentials struct {
+	md map[string]string
+}
+
+func (c *rpcCredentials) GetRequestMetadata(ctx context.Context,
+	uri ...string) (map[string]string, error) {
+	return c.md, nil
+}
+
+func (c *rpcCredentials) RequireTransportSecurity() bool { return true }
+
+// hasCredentials returns whether credentials are attached to the
+// Publish stream to the collector.
+func (cfg *collectorConfig) hasCredentials() bool {
+	return cfg.credentialsFile != "" || cfg.username != ""
+}
+
+// newRPCCredentials returns the credentials to attach to the Publish
+// stream to the collector, or nil if there are none. The credentials
+// file is read on every call, so that a rotated token is picked up the
+// next time the stream is established.
+func newRPCCredentials(cfg *collectorConfig) (credentials.PerRPCCredentials, error) {
+	if !cfg.hasCredentials() {
+		return nil, nil
+	}
+	md := make(map[string]string)
+	if cfg.credentialsFile != "" {
+		b, err := ioutil.ReadFile(cfg.credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return nil, fmt.Errorf("credentials file %s is empty", cfg.credentialsFile)
+		}
+		md["authorization"] = "Bearer " + token
+	}
+	if cfg.username != "" {
+		md["username"] = cfg.username
+		md["password"] = cfg.password
+	}
+	return &rpcCredentials{md: md}, nil
+}
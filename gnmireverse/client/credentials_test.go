@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aristanetworks/goarista/test"
+)
+
+func TestRPCCredentials(t *testing.T) {
+	tokenFile := writeConfigFile(t, "token1\n")
+	defer os.Remove(tokenFile)
+
+	for name, tc := range map[string]struct {
+		cfg collectorConfig
+		md  map[string]string
+	}{
+		"none": {},
+		"token": {
+			cfg: collectorConfig{credentialsFile: tokenFile},
+			md:  map[string]string{"authorization": "Bearer token1"},
+		},
+		"username": {
+			cfg: collectorConfig{username: "user", password: "pass"},
+			md:  map[string]string{"username": "user", "password": "pass"},
+		},
+		"token_and_username": {
+			cfg: collectorConfig{credentialsFile: tokenFile, username: "user"},
+			md: map[string]string{
+				"authorization": "Bearer token1",
+				"username":      "user",
+				"password":      "",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			creds, err := newRPCCredentials(&tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds == nil {
+				if tc.md != nil {
+					t.Fatal("expected credentials, got none")
+				}
+				return
+			}
+			md, err := creds.GetRequestMetadata(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.DeepEqual(tc.md, md) {
+				t.Errorf("unexpected metadata: %s", test.Diff(tc.md, md))
+			}
+		})
+	}
+}
+
+func TestRPCCredentialsRotation(t *testing.T) {
+	tokenFile := writeConfigFile(t, "token1")
+	defer os.Remove(tokenFile)
+	cfg := &collectorConfig{credentialsFile: tokenFile}
+
+	if _, err := newRPCCredentials(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(tokenFile, []byte("token2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := newRPCCredentials(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, _ := creds.GetRequestMetadata(context.Background())
+	if md["authorization"] != "Bearer token2" {
+		t.Errorf("expected rotated token, got %q", md["authorization"])
+	}
+
+	if err := ioutil.WriteFile(tokenFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newRPCCredentials(cfg); err == nil {
+		t.Error("expected error for empty credentials file")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// watchdog cancels the Subscribe stream to the target when the target
+// doesn't send a sync_response within syncTimeout of the start of the
+// stream, or any response within updateTimeout of the previous one,
+// so that a target that stopped sending updates over a connection
+// that is still up is resubscribed to. A zero timeout is disabled.
+type watchdog struct {
+	syncTimer     *time.Timer
+	updateTimer   *time.Timer
+	updateTimeout time.Duration
+
+	mu  sync.Mutex
+	err error
+}
+
+func newWatchdog(syncTimeout, updateTimeout time.Duration, cancel func()) *watchdog {
+	w := &watchdog{updateTimeout: updateTimeout}
+	expire := func(err error) func() {
+		return func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if w.err != nil {
+				return
+			}
+			w.err = err
+			targetTimeouts.Add(1)
+			cancel()
+		}
+	}
+	if syncTimeout > 0 {
+		w.syncTimer = time.AfterFunc(syncTimeout, expire(fmt.Errorf(
+			"no sync_response received from target within %s", syncTimeout)))
+	}
+	if updateTimeout > 0 {
+		w.updateTimer = time.AfterFunc(updateTimeout, expire(fmt.Errorf(
+			"no response received from target within %s", updateTimeout)))
+	}
+	return w
+}
+
+// received restarts the update timeout after resp was received, and
+// stops the sync timeout if resp is the sync_response.
+func (w *watchdog) received(resp *gnmi.SubscribeResponse) {
+	if w.syncTimer != nil && resp.GetSyncResponse() {
+		w.syncTimer.Stop()
+	}
+	if w.updateTimer != nil {
+		w.updateTimer.Reset(w.updateTimeout)
+	}
+}
+
+// expired returns the reason the watchdog canceled the stream, or nil
+// if it didn't.
+func (w *watchdog) expired() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *watchdog) stop() {
+	if w.syncTimer != nil {
+		w.syncTimer.Stop()
+	}
+	if w.updateTimer != nil {
+		w.updateTimer.Stop()
+	}
+}
+
+// heartbeat returns the notification of the heartbeat leaf of cfg,
+// whose value is lastResponse, the time in nanoseconds since the
+// epoch at which the last response was received from the target, or
+// 0 if none was received on the current Subscribe stream.
+func heartbeat(cfg *config, now time.Time, lastResponse int64) *gnmi.SubscribeResponse {
+	return &gnmi.SubscribeResponse{
+		Response: &gnmi.SubscribeResponse_Update{
+			Update: &gnmi.Notification{
+				Timestamp: now.UnixNano(),
+				Prefix:    &gnmi.Path{Target: cfg.targetVal},
+				Update: []*gnmi.Update{{
+					Path: cfg.heartbeatPath,
+					Val: &gnmi.TypedValue{
+						Value: &gnmi.TypedValue_UintVal{UintVal: uint64(lastResponse)},
+					},
+				}},
+			},
+		},
+	}
+}
+
+// isHeartbeat returns whether resp is a heartbeat published at path.
+func isHeartbeat(path *gnmi.Path, resp *gnmi.SubscribeResponse) bool {
+	notif := resp.GetUpdate()
+	return path != nil && len(notif.GetUpdate()) == 1 && len(notif.GetDelete()) == 0 &&
+		proto.Equal(notif.Update[0].Path, path)
+}
+
+// publishHeartbeats queues a heartbeat every cfg.heartbeatInterval
+// until ctx is done, so that collectors can tell a target that is
+// quiet, whose heartbeats keep coming with the time of the last
+// response from the target, from a target or client that is down,
+// whose heartbeats stop. lastResponse is accessed atomically.
+// Heartbeats aren't responses from the target, so they don't move the
+// start of the history replay, see recordPublished.
+func publishHeartbeats(ctx context.Context, cfg *config, q *queue, lastResponse *int64) {
+	ticker := time.NewTicker(cfg.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			resp := heartbeat(cfg, now, atomic.LoadInt64(lastResponse))
+			if resp = cfg.transforms.Transform(resp); resp == nil {
+				continue
+			}
+			if err := q.Put(ctx, resp); err != nil {
+				if ctx.Err() == nil {
+					targetLog.Errorf("error queuing heartbeat: %s", err)
+				}
+				return
+			}
+		}
+	}
+}
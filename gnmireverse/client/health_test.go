@@ -0,0 +1,148 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+)
+
+// quietTarget sends responses to each subscriber, then nothing.
+type quietTarget struct {
+	gnmi.UnimplementedGNMIServer
+	responses []*gnmi.SubscribeResponse
+}
+
+func (t *quietTarget) Subscribe(stream gnmi.GNMI_SubscribeServer) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	for _, resp := range t.responses {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func dialQuietTarget(t *testing.T, responses ...*gnmi.SubscribeResponse) (*grpc.ClientConn,
+	func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	gnmi.RegisterGNMIServer(s, &quietTarget{responses: responses})
+	go s.Serve(l)
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		s.Stop()
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestSubscribeTimeouts(t *testing.T) {
+	for name, tc := range map[string]struct {
+		responses     []*gnmi.SubscribeResponse
+		syncTimeout   time.Duration
+		updateTimeout time.Duration
+		err           string
+	}{
+		"no sync_response": {
+			responses:   []*gnmi.SubscribeResponse{notification(1, "a")},
+			syncTimeout: 50 * time.Millisecond,
+			err:         "no sync_response received from target within 50ms",
+		},
+		"no update": {
+			responses:     []*gnmi.SubscribeResponse{notification(1, "a"), syncResponse},
+			syncTimeout:   time.Minute,
+			updateTimeout: 50 * time.Millisecond,
+			err:           "no response received from target within 50ms",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, stop := dialQuietTarget(t, tc.responses...)
+			defer stop()
+			q, err := newQueue(10, queueDropOldest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config{syncTimeout: tc.syncTimeout, updateTimeout: tc.updateTimeout}
+			timeouts := targetTimeouts.Get()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err = subscribe(ctx, cfg, conn, q)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("subscribe returned after its context was done")
+			}
+			if n := targetTimeouts.Get() - timeouts; n != 1 {
+				t.Errorf("expected 1 timeout, got %d", n)
+			}
+			if n := len(drain(t, q)); n != len(tc.responses) {
+				t.Errorf("expected %d queued responses, got %d", len(tc.responses), n)
+			}
+		})
+	}
+}
+
+func TestSubscribeHeartbeat(t *testing.T) {
+	conn, stop := dialQuietTarget(t, notification(7, "a"), syncResponse)
+	defer stop()
+	q, err := newQueue(100, queueDropOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{
+		targetVal:         "device1",
+		syncTimeout:       50 * time.Millisecond,
+		heartbeatInterval: 10 * time.Millisecond,
+		heartbeatPath:     &gnmi.Path{Elem: []*gnmi.PathElem{{Name: "heartbeat"}}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now().UnixNano()
+	// The target sent its sync_response, so subscribe runs until ctx
+	// is done, publishing heartbeats.
+	if err := subscribe(ctx, cfg, conn, q); ctx.Err() == nil {
+		t.Fatalf("subscribe returned early: %v", err)
+	}
+
+	resps := drain(t, q)
+	if len(resps) < 4 || resps[0].GetUpdate().GetTimestamp() != 7 ||
+		!resps[1].GetSyncResponse() {
+		t.Fatalf("expected the sync_response and heartbeats, got %v", resps)
+	}
+	var last int64
+	for _, resp := range resps[2:] {
+		notif := resp.GetUpdate()
+		if notif.GetPrefix().GetTarget() != "device1" || len(notif.GetUpdate()) != 1 ||
+			notif.Update[0].Path.Elem[0].Name != "heartbeat" {
+			t.Fatalf("unexpected heartbeat %v", resp)
+		}
+		lastResponse := int64(notif.Update[0].Val.GetUintVal())
+		if lastResponse < start || lastResponse > notif.Timestamp {
+			t.Errorf("unexpected last response time %d in heartbeat at %d",
+				lastResponse, notif.Timestamp)
+		}
+		if last != 0 && lastResponse != last {
+			t.Errorf("expected the last response time to stay %d, got %d", last, lastResponse)
+		}
+		last = lastResponse
+	}
+}
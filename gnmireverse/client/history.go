@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	gnmilib "github.com/aristanetworks/goarista/gnmi"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+	"google.golang.org/grpc"
+)
+
+// lastPublished holds, by collector address, the timestamp in
+// nanoseconds since the epoch of the most recent notification from
+// the target that was sent to the collector. It is kept across config
+// reloads, and accessed with lastPublishedMu held.
+var (
+	lastPublishedMu sync.Mutex
+	lastPublished   = map[string]int64{}
+)
+
+// recordPublished records that resp was sent to the collector.
+// Heartbeats are stamped with the time of the client, so they don't
+// move the start of the history replay.
+func (c *collector) recordPublished(resp *gnmi.SubscribeResponse) {
+	ts := resp.GetUpdate().GetTimestamp()
+	if ts == 0 || isHeartbeat(c.heartbeatPath, resp) {
+		return
+	}
+	lastPublishedMu.Lock()
+	defer lastPublishedMu.Unlock()
+	if ts > lastPublished[c.cfg.addr] {
+		lastPublished[c.cfg.addr] = ts
+	}
+}
+
+// replayStart returns the timestamp of the last notification
+// published to the collectors of cfg, or 0 if none was published.
+// When every response is published to every collector, it is the
+// oldest of the last notifications published to each collector, so
+// that none of them misses updates. Collectors that weren't published
+// to yet are ignored.
+func replayStart(cfg *config) int64 {
+	lastPublishedMu.Lock()
+	defer lastPublishedMu.Unlock()
+	var start int64
+	for _, c := range cfg.collectors {
+		last := lastPublished[c.addr]
+		if last == 0 {
+			continue
+		}
+		if start == 0 || (cfg.deliveryMode == deliverAll && last < start) ||
+			(cfg.deliveryMode != deliverAll && last > start) {
+			start = last
+		}
+	}
+	return start
+}
+
+// replayHistory requests the updates from the target since the last
+// notification published to the collectors with the History
+// extension, see replayStart, and queues them. It returns once the
+// target has sent all the updates in that range. It does nothing if
+// no notification was published yet.
+//
+// The notifications received since the last published one are
+// requested again, so the updates that were dropped by the queue
+// policy or lost with a failed Publish stream are published, and
+// those that are still queued or spooled are published twice.
+func replayHistory(ctx context.Context, cfg *config, client gnmi.GNMIClient, q *queue) error {
+	last := replayStart(cfg)
+	if last == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start, end := time.Unix(0, last+1), time.Now()
+	request := subscribeRequest(cfg)
+	request.Extension = []*gnmi_ext.Extension{gnmilib.NewHistoryRangeExtension(start, end)}
+	stream, err := client.Subscribe(ctx, grpc.WaitForReady(true))
+	if err != nil {
+		return fmt.Errorf("error from Subscribe: %s", err)
+	}
+	if err := stream.Send(request); err != nil {
+		return fmt.Errorf("error sending SubscribeRequest: %s", err)
+	}
+	var replayed int
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error from Subscribe.Recv: %s", err)
+		}
+		if resp.GetSyncResponse() {
+			// The target has sent all the updates in the range.
+			break
+		}
+		targetResponses.Add(1)
+		if resp = cfg.transforms.Transform(resp); resp == nil {
+			continue
+		}
+		if err := q.Put(ctx, resp); err != nil {
+			return err
+		}
+		replayed++
+	}
+	targetLog.Infof("replayed %d responses in the history of the target from %s to %s",
+		replayed, start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano))
+	return nil
+}
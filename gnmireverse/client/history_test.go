@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+)
+
+// fakeGNMIClient replies to a Subscribe with responses.
+type fakeGNMIClient struct {
+	gnmi.GNMIClient
+	responses []*gnmi.SubscribeResponse
+	requests  []*gnmi.SubscribeRequest
+}
+
+func (c *fakeGNMIClient) Subscribe(ctx context.Context,
+	opts ...grpc.CallOption) (gnmi.GNMI_SubscribeClient, error) {
+	return &fakeSubscribeClient{c: c, responses: c.responses}, nil
+}
+
+type fakeSubscribeClient struct {
+	grpc.ClientStream
+	c         *fakeGNMIClient
+	responses []*gnmi.SubscribeResponse
+}
+
+func (s *fakeSubscribeClient) Send(req *gnmi.SubscribeRequest) error {
+	s.c.requests = append(s.c.requests, req)
+	return nil
+}
+
+func (s *fakeSubscribeClient) Recv() (*gnmi.SubscribeResponse, error) {
+	if len(s.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+// resetLastPublished discards the timestamps recorded by the tests
+// that publish responses.
+func resetLastPublished() {
+	lastPublishedMu.Lock()
+	defer lastPublishedMu.Unlock()
+	lastPublished = map[string]int64{}
+}
+
+func TestReplayHistory(t *testing.T) {
+	resetLastPublished()
+	defer resetLastPublished()
+	cfg := &config{
+		deliveryMode: deliverAll,
+		collectors:   []*collectorConfig{{addr: "collector1"}},
+	}
+	if err := cfg.subTargetDefined.Set("/a"); err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeGNMIClient{responses: []*gnmi.SubscribeResponse{
+		notification(11, "/a/b"),
+		notification(12, "/a/c"),
+		syncResponse,
+		notification(13, "/a/d"),
+	}}
+	q, err := newQueue(10, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing was published yet, so there is no history to replay.
+	if err := replayHistory(context.Background(), cfg, client, q); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.requests) != 0 {
+		t.Fatalf("unexpected requests: %v", client.requests)
+	}
+
+	c := &collector{cfg: cfg.collectors[0]}
+	c.recordPublished(notification(10, "/a/b"))
+	c.recordPublished(notification(5, "/a/c"))
+	if start := replayStart(cfg); start != 10 {
+		t.Fatalf("expected replay start 10, got %d", start)
+	}
+	if err := replayHistory(context.Background(), cfg, client, q); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.requests) != 1 || len(client.requests[0].Extension) != 1 {
+		t.Fatalf("expected a request with the History extension, got %v", client.requests)
+	}
+	var timestamps []int64
+	for _, resp := range drain(t, q) {
+		timestamps = append(timestamps, resp.GetUpdate().GetTimestamp())
+	}
+	// The responses after the sync_response are not part of the
+	// history.
+	if len(timestamps) != 2 || timestamps[0] != 11 || timestamps[1] != 12 {
+		t.Errorf("expected responses at 11 and 12, got %v", timestamps)
+	}
+	// The replayed responses weren't published yet, so the next replay
+	// must request them again.
+	if start := replayStart(cfg); start != 10 {
+		t.Errorf("expected replay start 10, got %d", start)
+	}
+}
+
+func TestReplayStart(t *testing.T) {
+	resetLastPublished()
+	defer resetLastPublished()
+	heartbeatPath := &gnmi.Path{Elem: []*gnmi.PathElem{{Name: "heartbeat"}}}
+	collectors := []*collectorConfig{{addr: "c1"}, {addr: "c2"}, {addr: "c3"}}
+	c1 := &collector{cfg: collectors[0], heartbeatPath: heartbeatPath}
+	c2 := &collector{cfg: collectors[1], heartbeatPath: heartbeatPath}
+	c1.recordPublished(notification(10, "a"))
+	c2.recordPublished(notification(20, "a"))
+	// Heartbeats are stamped with the time of the client
+	c1.recordPublished(notification(30, "heartbeat"))
+
+	for mode, exp := range map[string]int64{
+		// c1 missed the updates after 10, c3 didn't publish anything
+		deliverAll: 10,
+		deliverAny: 20,
+	} {
+		cfg := &config{deliveryMode: mode, collectors: collectors}
+		if start := replayStart(cfg); start != exp {
+			t.Errorf("%s: expected replay start %d, got %d", mode, exp, start)
+		}
+	}
+}
@@ -8,28 +8,44 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/aristanetworks/goarista/dscp"
+	gaflag "github.com/aristanetworks/goarista/flag"
 	gnmilib "github.com/aristanetworks/goarista/gnmi"
 	"github.com/aristanetworks/goarista/gnmireverse"
+	"github.com/aristanetworks/goarista/gnmireverse/transform"
+	"github.com/aristanetworks/goarista/logger"
+	"github.com/aristanetworks/goarista/monitor"
 	"github.com/aristanetworks/goarista/netns"
 
 	"github.com/aristanetworks/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/openconfig/gnmi/proto/gnmi"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 )
 
+// The loggers of the subsystems of the client, whose V level can be
+// set with -vsubsystem.
+var (
+	targetLog    = logger.New("target")
+	collectorLog = logger.New("collector")
+	spoolLog     = logger.New("spool")
+)
+
 type subscriptionList struct {
 	subs []subscription
 }
@@ -46,7 +62,7 @@ type subscription struct {
 func str(subs []subscription) string {
 	s := make([]string, len(subs))
 	for i, sub := range subs {
-		s[i] = gnmilib.StrPath(sub.p)
+		s[i] = gnmilib.StrPathWithOrigin(sub.p)
 		if sub.interval > 0 {
 			s[i] += "@" + sub.interval.String()
 		}
@@ -119,7 +135,30 @@ func (l *sampleList) Set(s string) error {
 	return setSubscriptions(&l.subs, s[:i], interval)
 }
 
+// collectorConfig is the configuration of the connection to one
+// collector.
+type collectorConfig struct {
+	addr       string
+	sourceAddr string
+	dscp       int
+	tls        bool
+	skipVerify bool
+	cert       string
+	key        string
+	ca         string
+
+	// credentialsFile holds a bearer token, and username and password
+	// are sent as metadata, on the Publish stream.
+	credentialsFile string
+	username        string
+	password        string
+}
+
 type config struct {
+	// configFile is the path of a YAML or JSON config file, whose
+	// settings override those given with flags.
+	configFile string
+
 	// target config
 	targetAddr string
 	username   string
@@ -129,22 +168,48 @@ type config struct {
 	subTargetDefined subscriptionList
 	subSample        sampleList
 	origin           string
+	historyReplay    bool
+	// transforms are applied to the responses received from the
+	// target, they are only set from the config file.
+	transforms transform.Chain
 
 	// collector config
-	collectorAddr       string
-	sourceAddr          string
-	dscp                int
-	collectorTLS        bool
-	collectorSkipVerify bool
-	collectorCert       string
-	collectorKey        string
-	collectorCA         string
+	collectorAddrs gaflag.StringArrayOption
+	// collectorFlags holds the settings given with flags, they apply
+	// to each of the collectorAddrs.
+	collectorFlags collectorConfig
+	collectors     []*collectorConfig
+	deliveryMode   string
+
+	// queue config
+	queueSize   int
+	queuePolicy string
+
+	// spool config
+	spoolDir      string
+	spoolMaxBytes int64
+
+	// transport config, shared by the target and collector connections
+	transport transportConfig
+
+	// retry config
+	backoff          backoffPolicy
+	errorLogInterval time.Duration
+
+	// target health config
+	syncTimeout       time.Duration
+	updateTimeout     time.Duration
+	heartbeatInterval time.Duration
+	heartbeatPath     *gnmi.Path
+
+	metricsAddr string
 }
 
 func main() {
 	var cfg config
 	flag.StringVar(&cfg.targetAddr, "target_addr", "127.0.0.1:6030",
-		"address of the gNMI target in the form of [<vrf-name>/]address:port")
+		"address of the gNMI target in the form of [<vrf-name>/]address:port,\n"+
+			"or unix:///path to connect to a unix domain socket")
 	flag.StringVar(&cfg.username, "username", "", "username to authenticate with target")
 	flag.StringVar(&cfg.password, "password", "", "password to authenticate with target")
 	flag.StringVar(&cfg.targetVal, "target_value", "",
@@ -153,6 +218,8 @@ func main() {
 		"Path to subscribe with TARGET_DEFINED subscription mode.\n"+
 			"To set a heartbeat interval include a suffix of @<heartbeat interval>.\n"+
 			"The interval should include a unit, such as 's' for seconds or 'm' for minutes.\n"+
+			"The path may start with an origin, such as eos_native:/Sysdb/...,\n"+
+			"which takes precedence over -origin.\n"+
 			"This option can be repeated multiple times.")
 	flag.Var(&cfg.subSample, "sample",
 		"Path to subscribe with SAMPLE subscription mode.\n"+
@@ -161,116 +228,410 @@ func main() {
 			"For example to subscribe to interface counters with a 30 second sample interval:\n"+
 			"  -sample /interfaces/interface/state/counters@30s\n"+
 			"This option can be repeated multiple times.")
-	flag.StringVar(&cfg.origin, "origin", "", "value for the origin field of the Subscribe")
-
-	flag.StringVar(&cfg.collectorAddr, "collector_addr", "",
+	flag.BoolVar(&cfg.historyReplay, "history_replay", false,
+		"When resubscribing to the target, first request the updates since the last\n"+
+			"notification published to the collectors with the gNMI History extension,\n"+
+			"so that updates that happened while disconnected from the target, or that\n"+
+			"were dropped before being published, are not lost.\n"+
+			"The target must support the History extension.")
+	flag.StringVar(&cfg.origin, "origin", "",
+		"value for the origin field of the subscription paths that don't specify one")
+
+	flag.Var(&cfg.collectorAddrs, "collector_addr",
 		"Address of collector in the form of [<vrf-name>/]host:port.\n"+
 			"The host portion must be enclosed in square brackets "+
 			"if it is a literal IPv6 address.\n"+
-			"For example, -collector_addr mgmt/[::1]:1234")
-	flag.StringVar(&cfg.sourceAddr, "source_addr", "",
+			"For example, -collector_addr mgmt/[::1]:1234\n"+
+			"The VRF doesn't need to exist when the client starts: connections are\n"+
+			"made once it is configured.\n"+
+			"A collector on the same host may be given as unix:///path of a unix\n"+
+			"domain socket.\n"+
+			"This option can be repeated to publish to multiple collectors, in which case\n"+
+			"the other collector options apply to each of them.")
+	flag.StringVar(&cfg.deliveryMode, "delivery_mode", deliverAll,
+		"How responses are published when there are multiple collectors:\n"+
+			"  '"+deliverAll+"' : publish every response to every collector, a collector\n"+
+			"    that falls behind stalls the others once its queue is full, unless\n"+
+			"    -queue_policy allows dropping responses\n"+
+			"  '"+deliverAny+"' : publish every response to the first reachable collector,\n"+
+			"    in the order they are given, failing over to the next collector when\n"+
+			"    the current one fails")
+	flag.StringVar(&cfg.collectorFlags.sourceAddr, "source_addr", "",
 		"Address to use as source in connection to collector in the form of ip[:port], or :port.\n"+
 			"An IPv6 address must be enclosed in square brackets when specified with a port.\n"+
 			"For example, [::1]:1234")
-	flag.IntVar(&cfg.dscp, "collector_dscp", 0,
+	flag.IntVar(&cfg.collectorFlags.dscp, "collector_dscp", 0,
 		"DSCP used on connection to collector, valid values 0-63")
 
-	flag.BoolVar(&cfg.collectorTLS, "collector_tls", true, "use TLS in connection with collector")
-	flag.BoolVar(&cfg.collectorSkipVerify, "collector_tls_skipverify", false,
+	flag.BoolVar(&cfg.collectorFlags.tls, "collector_tls", true,
+		"use TLS in connection with collector")
+	flag.BoolVar(&cfg.collectorFlags.skipVerify, "collector_tls_skipverify", false,
 		"don't verify collector's certificate (insecure)")
-	flag.StringVar(&cfg.collectorCert, "collector_certfile", "",
+	flag.StringVar(&cfg.collectorFlags.cert, "collector_certfile", "",
 		"path to TLS certificate file to authenticate with collector")
-	flag.StringVar(&cfg.collectorKey, "collector_keyfile", "",
+	flag.StringVar(&cfg.collectorFlags.key, "collector_keyfile", "",
 		"path to TLS key file to authenticate with collector")
-	flag.StringVar(&cfg.collectorCA, "collector_cafile", "",
+	flag.StringVar(&cfg.collectorFlags.ca, "collector_cafile", "",
 		"path to TLS CA file to verify collector (leave empty to use host's root CA set)")
-
+	flag.StringVar(&cfg.collectorFlags.credentialsFile, "collector_credentials_file", "",
+		"Path to a file containing a bearer token to authenticate with collector.\n"+
+			"The file is read each time the Publish stream is established, so a rotated\n"+
+			"token is picked up on the next reconnect. Requires -collector_tls.")
+	flag.StringVar(&cfg.collectorFlags.username, "collector_username", "",
+		"username to authenticate with collector, requires -collector_tls")
+	flag.StringVar(&cfg.collectorFlags.password, "collector_password", "",
+		"password to authenticate with collector")
+
+	flag.IntVar(&cfg.queueSize, "queue_size", 1000,
+		"number of SubscribeResponses buffered between the target and the collector")
+	flag.StringVar(&cfg.queuePolicy, "queue_policy", queueBlock,
+		"what to do when the buffer between the target and the collector is full:\n"+
+			"  '"+queueBlock+"' : stop reading from the target until there is room\n"+
+			"  '"+queueDropOldest+"' : discard the oldest buffered response\n"+
+			"  '"+queueCoalesce+"' : keep only the latest buffered value of each path,\n"+
+			"    discarding the oldest buffered response if the buffer is still full")
+
+	flag.StringVar(&cfg.spoolDir, "spool_dir", "",
+		"Directory used to store SubscribeResponses on disk while the collector is\n"+
+			"unreachable. Spooled responses are published in order once the collector\n"+
+			"is reachable again, including those left by a previous run.\n"+
+			"Each collector is given its own subdirectory. Spooling is not supported\n"+
+			"with multiple collectors in '"+deliverAny+"' delivery mode.\n"+
+			"Leave empty to disable spooling.")
+	flag.Int64Var(&cfg.spoolMaxBytes, "spool_max_bytes", 64<<20,
+		"maximum size of the spool of each collector, the oldest responses are\n"+
+			"discarded beyond this size. It must be at least 4096 bytes")
+
+	flag.DurationVar(&cfg.transport.keepaliveTime, "keepalive_time", 0,
+		"Interval of the gRPC keepalive pings sent to the target and collector when\n"+
+			"the connection is idle, which detects connections silently dropped by\n"+
+			"NAT devices or firewalls. The servers must allow pings this frequent,\n"+
+			"even without streams, e.g. with -keepalive_min_time of the gnmireverse\n"+
+			"server. 0 disables keepalives.")
+	flag.DurationVar(&cfg.transport.keepaliveTimeout, "keepalive_timeout", 20*time.Second,
+		"how long to wait for a keepalive ping to be acknowledged before closing the connection")
+	flag.IntVar(&cfg.transport.maxSendMsgSize, "max_send_msg_size", 0,
+		"maximum size in bytes of the gRPC messages sent to the target and collector,\n"+
+			"0 uses the gRPC default")
+	flag.IntVar(&cfg.transport.maxRecvMsgSize, "max_recv_msg_size", 0,
+		"maximum size in bytes of the gRPC messages received from the target and\n"+
+			"collector, 0 uses the gRPC default of 4MB")
+	flag.StringVar(&cfg.transport.compression, "compression", "",
+		"Compression used on the connections to the target and collector: 'gzip' or\n"+
+			"empty for none. The target and collector must support gzip compression.")
+
+	flag.DurationVar(&cfg.backoff.initial, "backoff_initial", time.Second,
+		"delay before the first retry after an error connecting to the target or collector")
+	flag.DurationVar(&cfg.backoff.max, "backoff_max", time.Minute,
+		"maximum delay between retries, the delay doubles after each consecutive error")
+	flag.Float64Var(&cfg.backoff.jitter, "backoff_jitter", 0.2,
+		"fraction by which the retry delay is randomized, in the range 0-1")
+	flag.DurationVar(&cfg.backoff.resetAfter, "backoff_reset", time.Minute,
+		"how long the target and collector streams must be up for the retry delay\n"+
+			"to return to its initial value")
+	flag.DurationVar(&cfg.errorLogInterval, "error_log_interval", 10*time.Second,
+		"minimum interval between logged retry errors, additional errors are counted\n"+
+			"and reported with the next logged error. It is also the interval at which\n"+
+			"responses dropped from full queues are logged, 0 disables that log")
+
+	flag.DurationVar(&cfg.syncTimeout, "sync_timeout", 0,
+		"resubscribe to the target if it doesn't send a sync_response within this\n"+
+			"duration of the start of the subscription, 0 to disable")
+	flag.DurationVar(&cfg.updateTimeout, "update_timeout", 0,
+		"resubscribe to the target if it doesn't send any response within this\n"+
+			"duration of the previous one, 0 to disable. It should be longer than the\n"+
+			"sample and heartbeat intervals of the subscriptions.")
+	flag.DurationVar(&cfg.heartbeatInterval, "heartbeat_interval", 0,
+		"interval at which a heartbeat leaf is published while the subscription to the\n"+
+			"target is up, 0 to disable. Its value is the time in nanoseconds since the\n"+
+			"epoch of the last response from the target, 0 if none was received yet.")
+	heartbeatPath := flag.String("heartbeat_path", "/gnmireverse/heartbeat",
+		"path of the heartbeat leaf published with -heartbeat_interval")
+
+	flag.StringVar(&cfg.metricsAddr, "metrics_addr", "",
+		"Address of an HTTP server exposing metrics about the health of the client,\n"+
+			"in the form of [<vrf-name>/]address:port. Metrics are served in the\n"+
+			"Prometheus format on /metrics and as expvar variables on /debug/vars.\n"+
+			"The V levels of the logs can be read and set on /debug/loglevel.\n"+
+			"Leave empty to disable.")
+	flag.StringVar(&cfg.configFile, "config_file", "",
+		"Path to a YAML or JSON file configuring the target, credentials, subscriptions\n"+
+			"and collectors. Settings in the file override those given with flags.\n"+
+			"The file is reloaded on SIGHUP, which re-establishes the subscription and\n"+
+			"the connections to the collectors with the new settings.")
+
+	logger.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	logger.HandleSignals()
 
-	if cfg.origin != "" {
-		// Workaround for EOS BUG479731: set origin on paths, rather
-		// than on the prefix.
-		for _, sub := range cfg.subTargetDefined.subs {
-			sub.p.Origin = cfg.origin
+	if err := cfg.backoff.validate(); err != nil {
+		glog.Fatal(err)
+	}
+	if err := cfg.transport.validate(); err != nil {
+		glog.Fatal(err)
+	}
+	if cfg.syncTimeout < 0 || cfg.updateTimeout < 0 || cfg.heartbeatInterval < 0 {
+		glog.Fatal("-sync_timeout, -update_timeout and -heartbeat_interval must not be negative")
+	}
+	if cfg.spoolDir != "" && cfg.spoolMaxBytes < minSpoolMaxBytes {
+		glog.Fatalf("-spool_max_bytes must be at least %d", minSpoolMaxBytes)
+	}
+	p, err := gnmilib.ParseGNMIElements(gnmilib.SplitPath(*heartbeatPath))
+	if err != nil {
+		glog.Fatalf("error parsing -heartbeat_path: %s", err)
+	}
+	cfg.heartbeatPath = p
+
+	// q is used to send subscribe responses from subscriber to
+	// publishers. It outlives each retry and config reload so that
+	// responses that weren't published yet are not lost.
+	q, err := newQueue(cfg.queueSize, cfg.queuePolicy)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	go q.logDrops(context.Background(), "target", cfg.errorLogInterval)
+	publishQueueMetrics(q)
+	if cfg.metricsAddr != "" {
+		go serveMetrics(cfg.metricsAddr)
+	}
+
+	runCfg, err := loadConfig(&cfg)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloads := make(chan *config)
+	go func() {
+		for {
+			reloads <- waitForReload(hup, &cfg)
+		}
+	}()
+	glog.Fatal(runConfigs(runCfg, reloads, func(ctx context.Context, cfg *config) error {
+		return run(ctx, cfg, q)
+	}))
+}
+
+// runConfigs calls run with cfg, then with each config received from
+// reloads, canceling the previous call. If run fails with a reloaded
+// config, the error is logged and run is called with the previous
+// config again. runConfigs returns the error of run with a config
+// that isn't a reloaded config.
+func runConfigs(cfg *config, reloads <-chan *config,
+	run func(context.Context, *config) error) error {
+	var prevCfg *config
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func(cfg *config) {
+			errc <- run(ctx, cfg)
+		}(cfg)
+		var reloaded *config
+		select {
+		case err := <-errc:
+			cancel()
+			if prevCfg == nil {
+				return err
+			}
+			glog.Errorf("error running with the reloaded config, "+
+				"going back to the previous config: %s", err)
+			cfg, prevCfg = prevCfg, nil
+			continue
+		case reloaded = <-reloads:
 		}
-		for _, sub := range cfg.subSample.subs {
-			sub.p.Origin = cfg.origin
+		glog.Info("received SIGHUP, reloading config")
+		cancel()
+		if err := <-errc; err != nil {
+			if prevCfg == nil {
+				return err
+			}
+			glog.Errorf("error running with the reloaded config: %s", err)
+			cfg = prevCfg
 		}
+		cfg, prevCfg = reloaded, cfg
 	}
+}
 
-	destConn, err := dialCollector(&cfg)
+// waitForReload waits for a SIGHUP and returns the reloaded config.
+// If the config can't be loaded, the error is logged and it waits for
+// the next SIGHUP.
+func waitForReload(hup <-chan os.Signal, flagCfg *config) *config {
+	for range hup {
+		cfg, err := loadConfig(flagCfg)
+		if err != nil {
+			glog.Errorf("error reloading config, keeping the current config: %s", err)
+			continue
+		}
+		return cfg
+	}
+	panic("unreachable")
+}
+
+// run subscribes to the target and publishes the responses to the
+// collectors until ctx is done.
+func run(ctx context.Context, cfg *config, q *queue) error {
+	collectors, err := newCollectors(cfg, q)
 	if err != nil {
-		glog.Fatalf("error dialing destination %q: %s", cfg.collectorAddr, err)
+		return err
 	}
-	targetConn, err := dialTarget(&cfg)
+	defer func() {
+		for _, c := range collectors {
+			c.close()
+		}
+	}()
+	targetConn, targetDialer, err := dialTarget(cfg)
 	if err != nil {
-		glog.Fatalf("error dialing target %q: %s", cfg.targetAddr, err)
+		return fmt.Errorf("error dialing target %q: %s", cfg.targetAddr, err)
+	}
+	defer func() {
+		targetConn.Close()
+		targetDialer.close()
+	}()
+	pruneCollectorQueues(collectors)
+
+	// The subscriber and publishers run independently, each retrying
+	// after a backoff delay when it encounters an error, so that
+	// responses keep being queued or spooled while a collector is
+	// unreachable.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runCollectors(ctx, cfg, q, collectors)
+	}()
+	retryLoop(ctx, "target "+cfg.targetAddr, cfg, &targetReconnects,
+		func(ctx context.Context) error {
+			return subscribe(ctx, cfg, targetConn, q)
+		}, sleep)
+	<-done
+	return nil
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
+}
 
+// retryLoop calls f until ctx is done, calling wait with the backoff
+// delay in between calls. retries counts the calls to f that failed.
+func retryLoop(ctx context.Context, name string, cfg *config, retries *monitor.Uint,
+	f func(context.Context) error, wait func(context.Context, time.Duration)) {
+	retry := newBackoff(&cfg.backoff)
+	errLog := &rateLimitedLogger{interval: cfg.errorLogInterval}
 	for {
-		// Start publisher and subscriber in a loop, each running in
-		// their own goroutine. If either of them encounters an error,
-		// retry.
-		eg, ctx := errgroup.WithContext(context.Background())
-		// c is used to send subscribe responses from subscriber to
-		// publisher.
-		c := make(chan *gnmi.SubscribeResponse)
-		eg.Go(func() error {
-			return publish(ctx, destConn, c)
-		})
-		eg.Go(func() error {
-			return subscribe(ctx, &cfg, targetConn, c)
-		})
-		err := eg.Wait()
+		retry.started()
+		err := f(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		delay := retry.next()
 		if err != nil {
-			glog.Errorf("encountered error, retrying: %s", err)
+			retries.Add(1)
+			errLog.Errorf("encountered error with %s, retrying in %s: %s", name, delay, err)
 		}
+		wait(ctx, delay)
 	}
 }
 
-func dialCollector(cfg *config) (*grpc.ClientConn, error) {
+func dialCollector(cfg *collectorConfig, bp *backoffPolicy,
+	tc *transportConfig) (*grpc.ClientConn, *vrfDialer, error) {
 	var dialOptions []grpc.DialOption
 
-	if cfg.collectorTLS {
-		tlsConfig, err := newTLSConfig(cfg.collectorSkipVerify,
-			cfg.collectorCert, cfg.collectorKey, cfg.collectorCA)
+	if cfg.tls {
+		tlsConfig, err := newTLSConfig(cfg.skipVerify, cfg.cert, cfg.key, cfg.ca)
 		if err != nil {
-			glog.Fatalf("error creating TLS config for collector: %s", err)
+			return nil, nil, fmt.Errorf("error creating TLS config for collector: %s", err)
+		}
+		if _, ok := gnmilib.ParseUnixAddress(cfg.addr); ok && !tlsConfig.InsecureSkipVerify {
+			// There is no host name to verify the collector's
+			// certificate against, only verify that it is signed by
+			// a trusted CA.
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyPeerCertificate = gnmilib.VerifyCertificateChain(tlsConfig.RootCAs)
 		}
 		dialOptions = append(dialOptions,
 			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	} else {
 		dialOptions = append(dialOptions, grpc.WithInsecure())
 	}
+	dialOptions = append(dialOptions, bp.dialOption())
+	dialOptions = append(dialOptions, tc.dialOptions()...)
 
-	nsName, addr, err := netns.ParseAddress(cfg.collectorAddr)
+	network, nsName, addr, err := parseAddress(cfg.addr)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing address: %s", err)
+		return nil, nil, fmt.Errorf("error parsing address: %s", err)
+	}
+	if network == "unix" && (cfg.sourceAddr != "" || cfg.dscp != 0) {
+		return nil, nil, fmt.Errorf("source address and DSCP are not supported with " +
+			"a unix domain socket")
 	}
 
 	dialer, err := newDialer(cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	dialOptions = append(dialOptions, grpc.WithContextDialer(newVRFDialer(dialer, nsName)))
-	return grpc.Dial(addr, dialOptions...)
+	vd, err := newVRFDialer(dialer, network, nsName)
+	if err != nil {
+		return nil, nil, err
+	}
+	dialOptions = append(dialOptions, grpc.WithContextDialer(vd.dial))
+	conn, err := grpc.Dial(addr, dialOptions...)
+	if err != nil {
+		vd.close()
+		return nil, nil, err
+	}
+	return conn, vd, nil
 }
 
-func newVRFDialer(d *net.Dialer, nsName string) func(context.Context, string) (net.Conn, error) {
-	return func(ctx context.Context, addr string) (net.Conn, error) {
-		var conn net.Conn
-		err := netns.Do(nsName, func() error {
-			c, err := d.DialContext(ctx, "tcp", addr)
-			if err != nil {
-				return err
-			}
-			conn = c
-			return nil
-		})
+// parseAddress returns the network, the network namespace and the
+// address to dial for addr, which is either of the form
+// [<vrf-name>/]address:port or unix:///path.
+func parseAddress(addr string) (network, nsName, address string, err error) {
+	if path, ok := gnmilib.ParseUnixAddress(addr); ok {
+		return "unix", "", path, nil
+	}
+	nsName, address, err = netns.ParseAddress(addr)
+	return "tcp", nsName, address, err
+}
+
+// vrfDialer dials addresses in a VRF, waiting for the VRF, which may
+// not be configured yet when the client starts. The VRF is watched
+// once for all the dials.
+type vrfDialer struct {
+	d       *net.Dialer
+	network string
+	waiter  *netns.Waiter
+}
 
-		return conn, err
+func newVRFDialer(d *net.Dialer, network, nsName string) (*vrfDialer, error) {
+	w, err := netns.NewWaiter(nsName)
+	if err != nil {
+		return nil, fmt.Errorf("error watching VRF %q: %s", nsName, err)
 	}
+	return &vrfDialer{d: d, network: network, waiter: w}, nil
+}
+
+func (vd *vrfDialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	var conn net.Conn
+	err := vd.waiter.DoEventually(ctx, func() error {
+		c, err := vd.d.DialContext(ctx, vd.network, addr)
+		if err != nil {
+			return err
+		}
+		conn = c
+		return nil
+	})
+	return conn, err
+}
+
+// close stops watching the VRF, once the connection using vd is closed.
+func (vd *vrfDialer) close() {
+	vd.waiter.Close()
 }
 
 func newTLSConfig(skipVerify bool, certFile, keyFile, caFile string) (*tls.Config,
@@ -302,7 +663,7 @@ func newTLSConfig(skipVerify bool, certFile, keyFile, caFile string) (*tls.Confi
 	return &tlsConfig, nil
 }
 
-func newDialer(cfg *config) (*net.Dialer, error) {
+func newDialer(cfg *collectorConfig) (*net.Dialer, error) {
 	var d net.Dialer
 	if cfg.sourceAddr != "" {
 		var localAddr net.TCPAddr
@@ -342,43 +703,115 @@ func newDialer(cfg *config) (*net.Dialer, error) {
 	return &d, nil
 }
 
-func dialTarget(cfg *config) (*grpc.ClientConn, error) {
-	nsName, addr, err := netns.ParseAddress(cfg.targetAddr)
+func dialTarget(cfg *config) (*grpc.ClientConn, *vrfDialer, error) {
+	network, nsName, addr, err := parseAddress(cfg.targetAddr)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing address: %s", err)
+		return nil, nil, fmt.Errorf("error parsing address: %s", err)
 	}
 
-	var d net.Dialer
+	vd, err := newVRFDialer(&net.Dialer{}, network, nsName)
+	if err != nil {
+		return nil, nil, err
+	}
 	dialOptions := []grpc.DialOption{
 		grpc.WithInsecure(),
-		grpc.WithContextDialer(newVRFDialer(&d, nsName)),
+		grpc.WithContextDialer(vd.dial),
+		cfg.backoff.dialOption(),
 	}
+	dialOptions = append(dialOptions, cfg.transport.dialOptions()...)
 
-	return grpc.Dial(addr, dialOptions...)
+	conn, err := grpc.Dial(addr, dialOptions...)
+	if err != nil {
+		vd.close()
+		return nil, nil, err
+	}
+	return conn, vd, nil
 }
 
-func publish(ctx context.Context, destConn *grpc.ClientConn,
-	c <-chan *gnmi.SubscribeResponse) error {
-	client := gnmireverse.NewGNMIReverseClient(destConn)
-	stream, err := client.Publish(ctx, grpc.WaitForReady(true))
+func publish(ctx context.Context, c *collector) error {
+	client := gnmireverse.NewGNMIReverseClient(c.conn)
+	// A collector used in turn with others must fail fast when it is
+	// unreachable, so that the next one is used.
+	callOptions := []grpc.CallOption{grpc.WaitForReady(!c.failover)}
+	creds, err := newRPCCredentials(c.cfg)
+	if err != nil {
+		return fmt.Errorf("error reading collector credentials: %s", err)
+	}
+	if creds != nil {
+		callOptions = append(callOptions, grpc.PerRPCCredentials(creds))
+	}
+	// The stream is canceled when publish returns, or when the
+	// collector ends it
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stream gnmireverse.GNMIReverse_PublishClient
+	openStream := func() error {
+		var err error
+		stream, err = client.Publish(streamCtx, callOptions...)
+		return err
+	}
+	if c.sp != nil {
+		// Spool the queued responses until the Publish stream is
+		// established.
+		err = c.sp.fillWhile(c.q, openStream)
+	} else {
+		err = openStream()
+	}
 	if err != nil {
 		return fmt.Errorf("error from Publish: %s", err)
 	}
+	c.metrics.streamUp.Set(1)
+	defer c.metrics.streamUp.Set(0)
+	collectorLog.V(1).Infof("publishing to collector %q", c.cfg.addr)
+	// The collector only responds once the stream is over, so wait for
+	// its response to notice a failed stream without waiting for the
+	// next response to send.
+	recvErr := make(chan error, 1)
+	go func() {
+		err := stream.RecvMsg(new(gnmireverse.Empty))
+		if err == nil {
+			err = errors.New("Publish stream closed by the collector")
+		}
+		recvErr <- err
+		cancel()
+	}()
+
+	send := func(response *gnmi.SubscribeResponse) error {
+		start := time.Now()
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+		c.metrics.sent(proto.Size(response), time.Since(start))
+		c.recordPublished(response)
+		collectorLog.V(9).Infof("published to collector %q: %s", c.cfg.addr, response)
+		return nil
+	}
+	if c.sp != nil {
+		if err := c.sp.replay(send); err != nil {
+			return fmt.Errorf("error publishing spooled responses: %s", err)
+		}
+	}
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case response := <-c:
-			if err := stream.Send(response); err != nil {
-				return fmt.Errorf("error from Publish.Send: %s", err)
+		response, err := c.q.Get(streamCtx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
 			}
+			return fmt.Errorf("error from Publish: %s", <-recvErr)
+		}
+		if err := send(response); err != nil {
+			if c.failover {
+				// Publish the response to the next collector
+				c.q.requeue(response)
+			}
+			return fmt.Errorf("error from Publish.Send: %s", err)
 		}
 	}
 }
 
-func subscribe(ctx context.Context, cfg *config, targetConn *grpc.ClientConn,
-	c chan<- *gnmi.SubscribeResponse) error {
-	client := gnmi.NewGNMIClient(targetConn)
+// subscribeRequest returns the SubscribeRequest for the subscriptions
+// of cfg.
+func subscribeRequest(cfg *config) *gnmi.SubscribeRequest {
 	subList := &gnmi.SubscriptionList{
 		Prefix: &gnmi.Path{Target: cfg.targetVal},
 	}
@@ -401,12 +834,16 @@ func subscribe(ctx context.Context, cfg *config, targetConn *grpc.ClientConn,
 			},
 		)
 	}
-	request := &gnmi.SubscribeRequest{
+	return &gnmi.SubscribeRequest{
 		Request: &gnmi.SubscribeRequest_Subscribe{
 			Subscribe: subList,
 		},
 	}
+}
 
+func subscribe(ctx context.Context, cfg *config, targetConn *grpc.ClientConn,
+	q *queue) error {
+	client := gnmi.NewGNMIClient(targetConn)
 	if cfg.username != "" {
 		ctx = metadata.NewOutgoingContext(ctx,
 			metadata.Pairs(
@@ -414,6 +851,20 @@ func subscribe(ctx context.Context, cfg *config, targetConn *grpc.ClientConn,
 				"password", cfg.password),
 		)
 	}
+	if cfg.historyReplay {
+		if err := replayHistory(ctx, cfg, client, q); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			targetLog.Errorf("error replaying history from target, updates published "+
+				"while disconnected may be missing: %s", err)
+		}
+	}
+
+	// The stream is canceled by the watchdog and when subscribe returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	request := subscribeRequest(cfg)
 	stream, err := client.Subscribe(ctx, grpc.WaitForReady(true))
 	if err != nil {
 		return fmt.Errorf("error from Subscribe: %s", err)
@@ -421,16 +872,36 @@ func subscribe(ctx context.Context, cfg *config, targetConn *grpc.ClientConn,
 	if err := stream.Send(request); err != nil {
 		return fmt.Errorf("error sending SubscribeRequest: %s", err)
 	}
+	targetLog.V(1).Infof("subscribed to %s", cfg.targetAddr)
+	targetStreamUp.Set(1)
+	defer targetStreamUp.Set(0)
+	wd := newWatchdog(cfg.syncTimeout, cfg.updateTimeout, cancel)
+	defer wd.stop()
+	var lastResponse int64
+	if cfg.heartbeatInterval > 0 {
+		go publishHeartbeats(ctx, cfg, q, &lastResponse)
+	}
 
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
+			if err := wd.expired(); err != nil {
+				return err
+			}
 			return fmt.Errorf("error from Subscribe.Recv: %s", err)
 		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case c <- resp:
+		targetResponses.Add(1)
+		wd.received(resp)
+		if resp.GetSyncResponse() {
+			targetLog.V(1).Info("received sync_response from target")
+		}
+		targetLog.V(9).Infof("received %s", resp)
+		atomic.StoreInt64(&lastResponse, time.Now().UnixNano())
+		if resp = cfg.transforms.Transform(resp); resp == nil {
+			continue
+		}
+		if err := q.Put(ctx, resp); err != nil {
+			return err
 		}
 	}
 }
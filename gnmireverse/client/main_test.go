@@ -140,3 +140,40 @@ func TestSubscriptionList(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAddress(t *testing.T) {
+	for name, tc := range map[string]struct {
+		addr    string
+		network string
+		nsName  string
+		address string
+	}{
+		"tcp": {
+			addr:    "127.0.0.1:6030",
+			network: "tcp",
+			address: "127.0.0.1:6030",
+		},
+		"vrf": {
+			addr:    "mgmt/[::1]:6030",
+			network: "tcp",
+			nsName:  "ns-mgmt",
+			address: "[::1]:6030",
+		},
+		"unix": {
+			addr:    "unix:///var/run/gnmi.sock",
+			network: "unix",
+			address: "/var/run/gnmi.sock",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			network, nsName, address, err := parseAddress(tc.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if network != tc.network || nsName != tc.nsName || address != tc.address {
+				t.Errorf("expected (%q, %q, %q), got (%q, %q, %q)",
+					tc.network, tc.nsName, tc.address, network, nsName, address)
+			}
+		})
+	}
+}
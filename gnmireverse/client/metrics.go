@@ -0,0 +1,166 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/aristanetworks/goarista/logger"
+	"github.com/aristanetworks/goarista/monitor"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Counters and gauges describing the health of the client. They are
+// exported with expvar, and in the Prometheus format on /metrics,
+// when -metrics_addr is set.
+var (
+	targetResponses  monitor.Uint
+	targetReconnects monitor.Uint
+	targetTimeouts   monitor.Uint
+	targetStreamUp   expvar.Int
+
+	// The collector metrics are maps keyed by collector address.
+	collectorResponses   = expvar.NewMap("collectorResponses")
+	collectorBytes       = expvar.NewMap("collectorBytes")
+	collectorReconnects  = expvar.NewMap("collectorReconnects")
+	collectorStreamUp    = expvar.NewMap("collectorStreamUp")
+	collectorSendSeconds = expvar.NewMap("collectorSendSeconds")
+	collectorQueueLength = expvar.NewMap("collectorQueueLength")
+	collectorSpoolBytes  = expvar.NewMap("collectorSpoolBytes")
+
+	// collectorSendLatency is only exported in the Prometheus format,
+	// so that percentiles can be computed from its buckets.
+	collectorSendLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gnmireverse_collector_send_latency_seconds",
+		Help:    "Time taken to send a SubscribeResponse to the collector",
+		Buckets: prometheus.ExponentialBuckets(10e-6, 4, 10),
+	}, []string{"collector"})
+)
+
+func init() {
+	expvar.Publish("targetResponses", &targetResponses)
+	expvar.Publish("targetReconnects", &targetReconnects)
+	expvar.Publish("targetTimeouts", &targetTimeouts)
+	expvar.Publish("targetStreamUp", &targetStreamUp)
+}
+
+// publishQueueMetrics exports the length and drop counters of the
+// subscriber's queue.
+func publishQueueMetrics(q *queue) {
+	expvar.Publish("queueLength", expvar.Func(func() interface{} { return q.Len() }))
+	expvar.Publish("queueDropped", expvar.Func(func() interface{} { return q.Dropped() }))
+	expvar.Publish("queueCoalesced", expvar.Func(func() interface{} { return q.Coalesced() }))
+}
+
+// collectorMetrics holds the metrics of one collector. The counters
+// of a collector are kept across config reloads.
+type collectorMetrics struct {
+	responses   *monitor.Uint
+	bytes       *monitor.Uint
+	reconnects  *monitor.Uint
+	streamUp    *expvar.Int
+	sendSeconds *expvar.Float
+	sendLatency prometheus.Observer
+}
+
+func newCollectorMetrics(c *collector) *collectorMetrics {
+	addr := c.cfg.addr
+	m := &collectorMetrics{
+		responses:   getOrSetUint(collectorResponses, addr),
+		bytes:       getOrSetUint(collectorBytes, addr),
+		reconnects:  getOrSetUint(collectorReconnects, addr),
+		streamUp:    new(expvar.Int),
+		sendSeconds: new(expvar.Float),
+		sendLatency: collectorSendLatency.WithLabelValues(addr),
+	}
+	if v, ok := collectorStreamUp.Get(addr).(*expvar.Int); ok {
+		m.streamUp = v
+	} else {
+		collectorStreamUp.Set(addr, m.streamUp)
+	}
+	if v, ok := collectorSendSeconds.Get(addr).(*expvar.Float); ok {
+		m.sendSeconds = v
+	} else {
+		collectorSendSeconds.Set(addr, m.sendSeconds)
+	}
+	q := c.q
+	collectorQueueLength.Set(addr, expvar.Func(func() interface{} { return q.Len() }))
+	if sp := c.sp; sp != nil {
+		collectorSpoolBytes.Set(addr, expvar.Func(func() interface{} { return sp.Len() }))
+	}
+	return m
+}
+
+func getOrSetUint(m *expvar.Map, key string) *monitor.Uint {
+	if v, ok := m.Get(key).(*monitor.Uint); ok {
+		return v
+	}
+	v := new(monitor.Uint)
+	m.Set(key, v)
+	return v
+}
+
+// sent records that resp was sent to the collector in d.
+func (m *collectorMetrics) sent(size int, d time.Duration) {
+	m.responses.Add(1)
+	m.bytes.Add(uint64(size))
+	m.sendSeconds.Add(d.Seconds())
+	m.sendLatency.Observe(d.Seconds())
+}
+
+// serveMetrics serves the expvar variables, and the Prometheus metrics
+// on /metrics, on addr. On error the program exits.
+func serveMetrics(addr string) {
+	prometheus.MustRegister(newMetricsCollector(), collectorSendLatency)
+	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/debug/loglevel", logger.Handler())
+	monitor.NewServer(addr).Run(http.DefaultServeMux)
+}
+
+// newMetricsCollector returns the Prometheus collector of the expvar
+// variables of the client.
+func newMetricsCollector() prometheus.Collector {
+	collectorLabel := []string{"collector"}
+	return prometheus.NewExpvarCollector(map[string]*prometheus.Desc{
+		"targetResponses": prometheus.NewDesc("gnmireverse_target_responses_total",
+			"SubscribeResponses received from the target", nil, nil),
+		"targetReconnects": prometheus.NewDesc("gnmireverse_target_reconnects_total",
+			"Subscribe streams to the target that failed and were retried", nil, nil),
+		"targetTimeouts": prometheus.NewDesc("gnmireverse_target_timeouts_total",
+			"Subscribe streams to the target canceled by -sync_timeout or -update_timeout",
+			nil, nil),
+		"targetStreamUp": prometheus.NewDesc("gnmireverse_target_stream_up",
+			"Whether the Subscribe stream to the target is established", nil, nil),
+		"queueLength": prometheus.NewDesc("gnmireverse_queue_length",
+			"SubscribeResponses waiting to be published", nil, nil),
+		"queueDropped": prometheus.NewDesc("gnmireverse_queue_dropped_total",
+			"SubscribeResponses dropped because the queue was full", nil, nil),
+		"queueCoalesced": prometheus.NewDesc("gnmireverse_queue_coalesced_total",
+			"SubscribeResponses discarded because newer ones superseded all of their paths",
+			nil, nil),
+		"collectorResponses": prometheus.NewDesc("gnmireverse_collector_responses_total",
+			"SubscribeResponses published to the collector", collectorLabel, nil),
+		"collectorBytes": prometheus.NewDesc("gnmireverse_collector_bytes_total",
+			"Bytes of SubscribeResponses published to the collector", collectorLabel, nil),
+		"collectorReconnects": prometheus.NewDesc("gnmireverse_collector_reconnects_total",
+			"Publish streams to the collector that failed and were retried",
+			collectorLabel, nil),
+		"collectorStreamUp": prometheus.NewDesc("gnmireverse_collector_stream_up",
+			"Whether the Publish stream to the collector is established", collectorLabel, nil),
+		"collectorSendSeconds": prometheus.NewDesc(
+			"gnmireverse_collector_send_seconds_total",
+			"Time spent sending SubscribeResponses to the collector", collectorLabel, nil),
+		"collectorQueueLength": prometheus.NewDesc("gnmireverse_collector_queue_length",
+			"SubscribeResponses waiting to be published to the collector",
+			collectorLabel, nil),
+		"collectorSpoolBytes": prometheus.NewDesc("gnmireverse_collector_spool_bytes",
+			"Bytes of SubscribeResponses spooled on disk for the collector",
+			collectorLabel, nil),
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/goarista/gnmireverse"
+	"github.com/aristanetworks/goarista/monitor"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+// fakeCollector passes the responses published to it to responses.
+type fakeCollector struct {
+	gnmireverse.UnimplementedGNMIReverseServer
+	responses chan *gnmi.SubscribeResponse
+}
+
+func (c *fakeCollector) Publish(stream gnmireverse.GNMIReverse_PublishServer) error {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		c.responses <- resp
+	}
+}
+
+func TestMetricsAfterPublish(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeCollector{responses: make(chan *gnmi.SubscribeResponse, 1)}
+	s := grpc.NewServer()
+	gnmireverse.RegisterGNMIReverseServer(s, fc)
+	go s.Serve(l)
+	defer s.Stop()
+
+	q, err := newQueue(10, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{collectors: []*collectorConfig{{addr: l.Addr().String()}}}
+	c, err := newCollector(cfg, cfg.collectors[0], q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go publish(ctx, c)
+	if err := q.Put(ctx, notification(1, "/a")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-fc.responses:
+	case <-ctx.Done():
+		t.Fatal("response not published to the collector")
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newMetricsCollector(), collectorSendLatency)
+	ts := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer ts.Close()
+	// The collector metrics are updated once the response is sent,
+	// which may be after the collector received it.
+	expected := []string{
+		fmt.Sprintf("gnmireverse_collector_responses_total{collector=%q} 1", c.cfg.addr),
+		fmt.Sprintf("gnmireverse_collector_stream_up{collector=%q} 1", c.cfg.addr),
+		"gnmireverse_collector_bytes_total{collector=",
+		fmt.Sprintf("gnmireverse_collector_send_latency_seconds_count{collector=%q} 1",
+			c.cfg.addr),
+		"gnmireverse_collector_send_latency_seconds_bucket{collector=",
+	}
+	var metrics string
+	for ctx.Err() == nil {
+		resp, err := http.Get(ts.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metrics = string(b); containsAll(metrics, expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected metrics %q, got:\n%s", expected, metrics)
+}
+
+func containsAll(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRetryLoopRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := &config{}
+	var retries monitor.Uint
+	errs := []error{nil, errors.New("failed"), nil}
+	retryLoop(ctx, "test", cfg, &retries, func(context.Context) error {
+		err := errs[0]
+		if errs = errs[1:]; len(errs) == 0 {
+			cancel()
+		}
+		return err
+	}, func(context.Context, time.Duration) {})
+	if n := retries.Get(); n != 1 {
+		t.Errorf("expected 1 retry counted for the failed call, got %d", n)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gnmilib "github.com/aristanetworks/goarista/gnmi"
+
+	"github.com/aristanetworks/glog"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Policies applied by a queue when it is full.
+const (
+	// queueBlock blocks the subscriber until the publisher makes
+	// room in the queue.
+	queueBlock = "block"
+	// queueDropOldest discards the oldest queued response to make
+	// room for the new one.
+	queueDropOldest = "drop_oldest"
+	// queueCoalesce removes the paths of a new notification from the
+	// queued ones, so that only the latest value of each path is
+	// published. Queued notifications left without any path are
+	// discarded. Atomic notifications are never coalesced. If the
+	// queue is still full the oldest queued response is discarded.
+	queueCoalesce = "coalesce"
+)
+
+// queue is a bounded FIFO of SubscribeResponses between the
+// subscriber and the publishers. It is safe for concurrent use.
+type queue struct {
+	policy string
+	size   int
+
+	mu    sync.Mutex
+	items *list.List // of *queueItem
+	// keys indexes the queued notification holding the latest value
+	// of each path by coalescing key. It is only used with the
+	// coalesce policy.
+	keys map[string]*list.Element
+
+	// notEmpty and notFull wake up a blocked Get and Put
+	// respectively.
+	notEmpty chan struct{}
+	notFull  chan struct{}
+
+	// dropped and coalesced count the responses that were discarded
+	// by the queue policy. They are accessed atomically.
+	dropped   uint64
+	coalesced uint64
+}
+
+type queueItem struct {
+	// keys are the coalescing keys of the paths updated and deleted
+	// by resp, in the order returned by coalesceKeys.
+	keys []string
+	resp *gnmi.SubscribeResponse
+}
+
+func newQueue(size int, policy string) (*queue, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("queue size must be at least 1, got %d", size)
+	}
+	q := &queue{
+		policy:   policy,
+		size:     size,
+		items:    list.New(),
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+	}
+	switch policy {
+	case queueBlock, queueDropOldest:
+	case queueCoalesce:
+		q.keys = make(map[string]*list.Element)
+	default:
+		return nil, fmt.Errorf("unknown queue policy: %q", policy)
+	}
+	return q, nil
+}
+
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// coalesceKeys returns a key for each path updated and then each path
+// deleted by resp, or nil if resp must never be coalesced.
+func coalesceKeys(resp *gnmi.SubscribeResponse) []string {
+	notif := resp.GetUpdate()
+	if notif == nil || notif.Atomic {
+		return nil
+	}
+	prefix := notif.Prefix.GetTarget() + " " + notif.Prefix.GetOrigin() + " " +
+		gnmilib.StrPath(notif.Prefix)
+	keys := make([]string, 0, len(notif.Update)+len(notif.Delete))
+	for _, u := range notif.Update {
+		keys = append(keys, prefix+" "+gnmilib.StrPathWithOrigin(u.Path))
+	}
+	for _, d := range notif.Delete {
+		keys = append(keys, prefix+" "+gnmilib.StrPathWithOrigin(d))
+	}
+	return keys
+}
+
+// supersede removes the paths identified by keys from the queued
+// notifications, which are replaced by a newer notification. Queued
+// notifications left without any path are removed from the queue. It
+// must be called with q.mu held.
+func (q *queue) supersede(keys []string) {
+	stale := make(map[*list.Element]map[string]struct{})
+	for _, k := range keys {
+		e, ok := q.keys[k]
+		if !ok {
+			continue
+		}
+		if stale[e] == nil {
+			stale[e] = make(map[string]struct{})
+		}
+		stale[e][k] = struct{}{}
+	}
+	for e, paths := range stale {
+		item := e.Value.(*queueItem)
+		notif := item.resp.GetUpdate()
+		// The response may also be queued for other collectors, so
+		// it is copied rather than modified.
+		stripped := &gnmi.Notification{
+			Timestamp: notif.Timestamp,
+			Prefix:    notif.Prefix,
+			Alias:     notif.Alias,
+		}
+		var itemKeys []string
+		for i, k := range item.keys {
+			if _, ok := paths[k]; ok {
+				delete(q.keys, k)
+				continue
+			}
+			itemKeys = append(itemKeys, k)
+			if i < len(notif.Update) {
+				stripped.Update = append(stripped.Update, notif.Update[i])
+			} else {
+				stripped.Delete = append(stripped.Delete, notif.Delete[i-len(notif.Update)])
+			}
+		}
+		if len(itemKeys) == 0 {
+			q.remove(e)
+			atomic.AddUint64(&q.coalesced, 1)
+			continue
+		}
+		item.keys = itemKeys
+		item.resp = &gnmi.SubscribeResponse{
+			Response:  &gnmi.SubscribeResponse_Update{Update: stripped},
+			Extension: item.resp.Extension,
+		}
+	}
+}
+
+// Put adds resp to the queue, applying the queue policy if the queue
+// is full. With the block policy Put waits until there is room in the
+// queue or ctx is done.
+func (q *queue) Put(ctx context.Context, resp *gnmi.SubscribeResponse) error {
+	var keys []string
+	if q.keys != nil {
+		keys = coalesceKeys(resp)
+	}
+	for {
+		q.mu.Lock()
+		if len(keys) > 0 {
+			// The newer response is queued at the back, after the
+			// responses queued since the older values of its paths,
+			// such as a delete of other paths or a sync_response.
+			q.supersede(keys)
+		}
+		if q.items.Len() >= q.size {
+			if q.policy == queueBlock {
+				q.mu.Unlock()
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-q.notFull:
+				}
+				continue
+			}
+			q.remove(q.items.Front())
+			atomic.AddUint64(&q.dropped, 1)
+		}
+		e := q.items.PushBack(&queueItem{keys: keys, resp: resp})
+		for _, k := range keys {
+			q.keys[k] = e
+		}
+		q.mu.Unlock()
+		notify(q.notEmpty)
+		return nil
+	}
+}
+
+// Get removes and returns the oldest response in the queue, waiting
+// until there is one or ctx is done.
+func (q *queue) Get(ctx context.Context) (*gnmi.SubscribeResponse, error) {
+	for {
+		q.mu.Lock()
+		if e := q.items.Front(); e != nil {
+			q.remove(e)
+			more := q.items.Len() > 0
+			q.mu.Unlock()
+			notify(q.notFull)
+			if more {
+				// Pass the wakeup on to other consumers
+				notify(q.notEmpty)
+			}
+			return e.Value.(*queueItem).resp, nil
+		}
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.notEmpty:
+		}
+	}
+}
+
+// requeue adds resp at the front of the queue, so that it is returned
+// by the next Get, even if the queue is full. It is used to hand a
+// response that couldn't be published to another publisher.
+func (q *queue) requeue(resp *gnmi.SubscribeResponse) {
+	q.mu.Lock()
+	q.items.PushFront(&queueItem{resp: resp})
+	q.mu.Unlock()
+	notify(q.notEmpty)
+}
+
+// take removes and returns all the responses in the queue, oldest
+// first.
+func (q *queue) take() []*gnmi.SubscribeResponse {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var resps []*gnmi.SubscribeResponse
+	for e := q.items.Front(); e != nil; e = q.items.Front() {
+		q.remove(e)
+		resps = append(resps, e.Value.(*queueItem).resp)
+	}
+	notify(q.notFull)
+	return resps
+}
+
+// remove must be called with q.mu held.
+func (q *queue) remove(e *list.Element) {
+	item := q.items.Remove(e).(*queueItem)
+	for _, k := range item.keys {
+		if q.keys[k] == e {
+			delete(q.keys, k)
+		}
+	}
+}
+
+// Len returns the number of responses in the queue.
+func (q *queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Dropped returns the number of responses discarded to make room in
+// the queue.
+func (q *queue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Coalesced returns the number of responses discarded because newer
+// responses superseded all of their paths.
+func (q *queue) Coalesced() uint64 {
+	return atomic.LoadUint64(&q.coalesced)
+}
+
+// logDrops periodically logs the number of responses discarded by the
+// queue policy, if any were discarded since the last check, until ctx
+// is done. name identifies the queue in the log. Nothing is logged if
+// interval isn't positive.
+func (q *queue) logDrops(ctx context.Context, name string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	var lastDropped, lastCoalesced uint64
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		dropped, coalesced := q.Dropped(), q.Coalesced()
+		if dropped != lastDropped || coalesced != lastCoalesced {
+			glog.Errorf("%s queue is full: %d responses dropped and "+
+				"%d coalesced in the last %s (%d and %d in total)", name,
+				dropped-lastDropped, coalesced-lastCoalesced, interval, dropped, coalesced)
+		}
+		lastDropped, lastCoalesced = dropped, coalesced
+	}
+}
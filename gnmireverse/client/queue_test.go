@@ -0,0 +1,249 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func notification(ts int64, paths ...string) *gnmi.SubscribeResponse {
+	notif := &gnmi.Notification{Timestamp: ts}
+	for _, p := range paths {
+		notif.Update = append(notif.Update, &gnmi.Update{
+			Path: &gnmi.Path{Elem: []*gnmi.PathElem{&gnmi.PathElem{Name: p}}},
+		})
+	}
+	return &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notif}}
+}
+
+func deletion(ts int64, paths ...string) *gnmi.SubscribeResponse {
+	notif := &gnmi.Notification{Timestamp: ts}
+	for _, p := range paths {
+		notif.Delete = append(notif.Delete,
+			&gnmi.Path{Elem: []*gnmi.PathElem{&gnmi.PathElem{Name: p}}})
+	}
+	return &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notif}}
+}
+
+var syncResponse = &gnmi.SubscribeResponse{
+	Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true},
+}
+
+func drain(t *testing.T, q *queue) []*gnmi.SubscribeResponse {
+	var out []*gnmi.SubscribeResponse
+	for q.Len() > 0 {
+		resp, err := q.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, resp)
+	}
+	return out
+}
+
+func TestQueuePolicies(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy string
+		in     []*gnmi.SubscribeResponse
+
+		exp       []int64 // timestamps of the responses left, 0 for a sync_response
+		dropped   uint64
+		coalesced uint64
+	}{
+		"drop_oldest": {
+			policy: queueDropOldest,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a"), notification(2, "b"), notification(3, "a"),
+				notification(4, "c"),
+			},
+			exp:     []int64{2, 3, 4},
+			dropped: 1,
+		},
+		"coalesce": {
+			policy: queueCoalesce,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a"), notification(2, "b"), syncResponse,
+				notification(3, "a"), notification(4, "a", "b"), notification(5, "c"),
+				notification(6, "d"),
+			},
+			exp:       []int64{4, 5, 6},
+			dropped:   1,
+			coalesced: 3,
+		},
+		"coalesce_after_delete": {
+			policy: queueCoalesce,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a"), deletion(2, "a", "b"), notification(3, "a"),
+			},
+			exp:       []int64{2, 3},
+			coalesced: 1,
+		},
+		"coalesce_overlapping": {
+			policy: queueCoalesce,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a", "b"), notification(2, "b", "c"), notification(3, "a"),
+				notification(4, "d"),
+			},
+			exp:       []int64{2, 3, 4},
+			coalesced: 1,
+		},
+		"coalesce_leaf_supersedes_notification": {
+			policy: queueCoalesce,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a", "b"), notification(2, "a"), notification(3, "b"),
+			},
+			exp:       []int64{2, 3},
+			coalesced: 1,
+		},
+		"coalesce_after_sync_response": {
+			policy: queueCoalesce,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a"), notification(2, "b"), syncResponse,
+				notification(3, "a"),
+			},
+			exp:       []int64{2, 0, 3},
+			coalesced: 1,
+		},
+		"coalesce_no_drops": {
+			policy: queueCoalesce,
+			in: []*gnmi.SubscribeResponse{
+				notification(1, "a"), notification(2, "a"), notification(3, "a"),
+			},
+			exp:       []int64{3},
+			coalesced: 2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			q, err := newQueue(3, tc.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, resp := range tc.in {
+				if err := q.Put(context.Background(), resp); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out := drain(t, q)
+			if len(out) != len(tc.exp) {
+				t.Fatalf("expected %d responses, got %d: %v", len(tc.exp), len(out), out)
+			}
+			for i, resp := range out {
+				if ts := resp.GetUpdate().GetTimestamp(); ts != tc.exp[i] {
+					t.Errorf("[%d] expected timestamp %d, got %d", i, tc.exp[i], ts)
+				}
+			}
+			if d := q.Dropped(); d != tc.dropped {
+				t.Errorf("expected %d dropped, got %d", tc.dropped, d)
+			}
+			if c := q.Coalesced(); c != tc.coalesced {
+				t.Errorf("expected %d coalesced, got %d", tc.coalesced, c)
+			}
+		})
+	}
+}
+
+func paths(resp *gnmi.SubscribeResponse) []string {
+	var out []string
+	for _, u := range resp.GetUpdate().GetUpdate() {
+		out = append(out, "u"+u.Path.Elem[0].Name)
+	}
+	for _, d := range resp.GetUpdate().GetDelete() {
+		out = append(out, "d"+d.Elem[0].Name)
+	}
+	return out
+}
+
+func TestQueueCoalescePaths(t *testing.T) {
+	q, err := newQueue(10, queueCoalesce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := notification(1, "a", "b", "c")
+	atomicNotif := notification(2, "a")
+	atomicNotif.GetUpdate().Atomic = true
+	for _, resp := range []*gnmi.SubscribeResponse{
+		first, deletion(3, "c", "d"), atomicNotif, notification(4, "b"), notification(5, "d"),
+	} {
+		if err := q.Put(context.Background(), resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := [][]string{{"ua"}, {"dc"}, {"ua"}, {"ub"}, {"ud"}}
+	out := drain(t, q)
+	if len(out) != len(exp) {
+		t.Fatalf("expected %d responses, got %d: %v", len(exp), len(out), out)
+	}
+	for i, resp := range out {
+		if got := paths(resp); !reflect.DeepEqual(got, exp[i]) {
+			t.Errorf("[%d] expected paths %v, got %v", i, exp[i], got)
+		}
+	}
+	if got := paths(first); !reflect.DeepEqual(got, []string{"ua", "ub", "uc"}) {
+		t.Errorf("the queued response was modified: %v", got)
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	q, err := newQueue(1, queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(context.Background(), notification(1, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Put(ctx, notification(2, "a")); err != context.DeadlineExceeded {
+		t.Fatalf("expected Put on a full queue to block until timeout, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- q.Put(context.Background(), notification(3, "a"))
+	}()
+	if resp, err := q.Get(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if ts := resp.GetUpdate().GetTimestamp(); ts != 1 {
+		t.Errorf("expected timestamp 1, got %d", ts)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := q.Get(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if ts := resp.GetUpdate().GetTimestamp(); ts != 3 {
+		t.Errorf("expected timestamp 3, got %d", ts)
+	}
+	if q.Dropped() != 0 {
+		t.Errorf("expected no drops, got %d", q.Dropped())
+	}
+
+	if _, err := newQueue(1, "bogus"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestQueueLogDropsDisabled(t *testing.T) {
+	q, err := newQueue(1, queueDropOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.logDrops(context.Background(), "test", 0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logDrops didn't return with a zero interval")
+	}
+}
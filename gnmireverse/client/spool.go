@@ -0,0 +1,329 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const (
+	spoolSuffix = ".spool"
+	// maxSpoolSegmentBytes is the size at which the spool starts
+	// writing to a new segment file.
+	maxSpoolSegmentBytes = 4 << 20
+	// maxSpoolResponseBytes is the size above which a response isn't
+	// spooled, so that a corrupt length read back from a segment
+	// can't make the spool allocate an arbitrary amount of memory.
+	maxSpoolResponseBytes = 64 << 20
+	// minSpoolMaxBytes is the smallest spool size, with segments of at
+	// least a quarter of it.
+	minSpoolMaxBytes = 4 << 10
+)
+
+// spool stores SubscribeResponses on disk while the Publish stream is
+// down, so they can be published once the collector is reachable
+// again. Responses are written to a sequence of segment files in dir,
+// each containing varint length-delimited SubscribeResponses.
+//
+// When the spool grows beyond maxBytes the oldest segment is removed.
+// A segment is only removed after its responses have all been sent,
+// so if the Publish stream fails during a replay the responses of the
+// segment being replayed are sent again.
+//
+// A spool is not safe for concurrent use.
+type spool struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	// segments holds the sequence numbers of the segment files on
+	// disk, oldest first.
+	segments []uint64
+	// size is the total size of the segment files. It is accessed
+	// atomically.
+	size int64
+
+	// w is the segment currently being written, it is the last one
+	// in segments.
+	w     *os.File
+	wbuf  *bufio.Writer
+	wsize int64
+
+	// droppedBytes counts the bytes of responses removed to stay
+	// within maxBytes. It is accessed atomically.
+	droppedBytes uint64
+}
+
+// newSpool returns a spool that stores responses in dir. Segments
+// left in dir by a previous run are replayed before any new
+// responses.
+func newSpool(dir string, maxBytes int64) (*spool, error) {
+	if maxBytes < minSpoolMaxBytes {
+		return nil, fmt.Errorf("spool max bytes must be at least %d, got %d",
+			minSpoolMaxBytes, maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &spool{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: maxSpoolSegmentBytes,
+	}
+	if s.segmentBytes > maxBytes/4 {
+		s.segmentBytes = maxBytes / 4
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, seq)
+		s.size += f.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+	if len(s.segments) > 0 {
+		spoolLog.Infof("found %d bytes of spooled responses in %s", s.size, dir)
+	}
+	return s, nil
+}
+
+func (s *spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolSuffix))
+}
+
+// Len returns the number of bytes of spooled responses.
+func (s *spool) Len() int64 {
+	return atomic.LoadInt64(&s.size)
+}
+
+// DroppedBytes returns the number of bytes of spooled responses that
+// were discarded to stay within the maximum spool size.
+func (s *spool) DroppedBytes() uint64 {
+	return atomic.LoadUint64(&s.droppedBytes)
+}
+
+// fill moves responses from q to the spool until ctx is done.
+func (s *spool) fill(ctx context.Context, q *queue) error {
+	defer s.flush()
+	for {
+		resp, err := q.Get(ctx)
+		if err != nil {
+			if err == ctx.Err() {
+				return nil
+			}
+			return err
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// fillWhile moves responses from q to the spool while f runs, and
+// returns the result of f.
+func (s *spool) fillWhile(q *queue, f func() error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error, 1)
+	go func() {
+		errC <- s.fill(ctx, q)
+	}()
+	err := f()
+	cancel()
+	if spoolErr := <-errC; spoolErr != nil {
+		spoolLog.Errorf("error spooling responses: %s", spoolErr)
+	}
+	return err
+}
+
+func (s *spool) write(resp *gnmi.SubscribeResponse) error {
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if len(b) > maxSpoolResponseBytes {
+		atomic.AddUint64(&s.droppedBytes, uint64(len(b)))
+		spoolLog.Errorf("discarded response of %d bytes, larger than the maximum of %d bytes",
+			len(b), maxSpoolResponseBytes)
+		return nil
+	}
+	if s.w != nil && s.wsize+int64(len(b)) > s.segmentBytes {
+		if err := s.closeSegment(); err != nil {
+			return err
+		}
+	}
+	if s.w == nil {
+		if err := s.openSegment(); err != nil {
+			return err
+		}
+	}
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(b)))
+	if _, err := s.wbuf.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	if _, err := s.wbuf.Write(b); err != nil {
+		return err
+	}
+	s.wsize += int64(n + len(b))
+	atomic.AddInt64(&s.size, int64(n+len(b)))
+	return s.trim()
+}
+
+func (s *spool) openSegment() error {
+	var seq uint64
+	if len(s.segments) > 0 {
+		seq = s.segments[len(s.segments)-1] + 1
+	}
+	f, err := os.OpenFile(s.path(seq), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	s.segments = append(s.segments, seq)
+	s.w = f
+	s.wbuf = bufio.NewWriter(f)
+	s.wsize = 0
+	return nil
+}
+
+func (s *spool) closeSegment() error {
+	if s.w == nil {
+		return nil
+	}
+	err := s.wbuf.Flush()
+	if cerr := s.w.Close(); err == nil {
+		err = cerr
+	}
+	s.w, s.wbuf = nil, nil
+	return err
+}
+
+// close flushes and closes the segment being written.
+func (s *spool) close() error {
+	return s.closeSegment()
+}
+
+func (s *spool) flush() {
+	if s.wbuf != nil {
+		if err := s.wbuf.Flush(); err != nil {
+			spoolLog.Errorf("error flushing spool: %s", err)
+		}
+	}
+}
+
+// trim removes the oldest segments until the spool fits in maxBytes.
+// The segment being written is never removed.
+func (s *spool) trim() error {
+	for s.Len() > s.maxBytes && len(s.segments) > 1 {
+		p := s.path(s.segments[0])
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		s.segments = s.segments[1:]
+		atomic.AddInt64(&s.size, -fi.Size())
+		atomic.AddUint64(&s.droppedBytes, uint64(fi.Size()))
+		spoolLog.Errorf("spool is full, discarded %d bytes of spooled responses", fi.Size())
+	}
+	return nil
+}
+
+// replay calls send with each spooled response, oldest first. Each
+// segment is removed once all of its responses have been sent.
+func (s *spool) replay(send func(*gnmi.SubscribeResponse) error) error {
+	if err := s.closeSegment(); err != nil {
+		return err
+	}
+	for len(s.segments) > 0 {
+		p := s.path(s.segments[0])
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if err := replaySegment(p, send); err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		s.segments = s.segments[1:]
+		atomic.AddInt64(&s.size, -fi.Size())
+	}
+	return nil
+}
+
+// replaySegment calls send with each response in the segment file at
+// p.
+func replaySegment(p string, send func(*gnmi.SubscribeResponse) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		l, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return truncated(p, err)
+		}
+		if l > maxSpoolResponseBytes {
+			// The rest of the segment can't be framed without a valid
+			// length, so it is skipped.
+			spoolLog.Errorf("skipping the rest of spool segment %s: corrupt response length %d",
+				p, l)
+			return nil
+		}
+		b := make([]byte, l)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return truncated(p, err)
+		}
+		var resp gnmi.SubscribeResponse
+		if err := proto.Unmarshal(b, &resp); err != nil {
+			spoolLog.Errorf("skipping corrupt response in spool segment %s: %s", p, err)
+			continue
+		}
+		if err := send(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+// truncated handles a segment that ends in a partially written
+// response, which can happen if the process died while writing it.
+// The partial response is discarded.
+func truncated(p string, err error) error {
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		spoolLog.Errorf("discarding partially written response at the end of spool segment %s", p)
+		return nil
+	}
+	return err
+}
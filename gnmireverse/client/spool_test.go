@@ -0,0 +1,176 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func fillSpool(t *testing.T, s *spool, resps ...*gnmi.SubscribeResponse) {
+	q, err := newQueue(len(resps), queueBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, resp := range resps {
+		if err := q.Put(context.Background(), resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.fillWhile(q, func() error {
+		for q.Len() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func replayTimestamps(t *testing.T, s *spool) []int64 {
+	var ts []int64
+	if err := s.replay(func(resp *gnmi.SubscribeResponse) error {
+		ts = append(ts, resp.GetUpdate().GetTimestamp())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSpool(t, s, notification(1, "a"), notification(2, "b"))
+	fillSpool(t, s, notification(3, "c"))
+
+	// A failed replay keeps the responses in the spool
+	sendErr := errors.New("send failed")
+	if err := s.replay(func(*gnmi.SubscribeResponse) error {
+		return sendErr
+	}); err != sendErr {
+		t.Fatalf("expected error %v, got %v", sendErr, err)
+	}
+
+	// Spooled responses survive a restart
+	s, err = newSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSpool(t, s, notification(4, "d"))
+	exp := []int64{1, 2, 3, 4}
+	ts := replayTimestamps(t, s)
+	if len(ts) != len(exp) {
+		t.Fatalf("expected timestamps %v, got %v", exp, ts)
+	}
+	for i := range exp {
+		if ts[i] != exp[i] {
+			t.Fatalf("expected timestamps %v, got %v", exp, ts)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("expected empty spool after replay, got %d bytes", s.Len())
+	}
+	if ts := replayTimestamps(t, s); len(ts) != 0 {
+		t.Errorf("expected nothing to replay, got %v", ts)
+	}
+}
+
+func TestSpoolCorruptLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSpool(t, s, notification(1, "a"))
+	if err := s.close(); err != nil {
+		t.Fatal(err)
+	}
+	// Append a length far larger than any response to the segment
+	f, err := os.OpenFile(s.path(s.segments[0]), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], 1<<62)
+	if _, err := f.Write(append(lenBuf[:n], "garbage"...)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s, err = newSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSpool(t, s, notification(2, "b"))
+	if ts := replayTimestamps(t, s); len(ts) != 2 || ts[0] != 1 || ts[1] != 2 {
+		t.Errorf("expected timestamps [1 2], got %v", ts)
+	}
+	if s.Len() != 0 {
+		t.Errorf("expected empty spool after replay, got %d bytes", s.Len())
+	}
+}
+
+func TestSpoolMaxBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Room for about 4 responses, with one response per segment
+	name := strings.Repeat("a", minSpoolMaxBytes/4)
+	size := int64(proto.Size(notification(1, name)))
+	size += int64(len(proto.EncodeVarint(uint64(size))))
+	s, err := newSpool(dir, 4*size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 10; i++ {
+		fillSpool(t, s, notification(i, name))
+	}
+	if s.DroppedBytes() != uint64(6*size) {
+		t.Errorf("expected %d bytes dropped, got %d", 6*size, s.DroppedBytes())
+	}
+	ts := replayTimestamps(t, s)
+	if len(ts) != 4 || ts[0] != 7 || ts[3] != 10 {
+		t.Errorf("expected the 4 newest responses, got %v", ts)
+	}
+}
+
+func TestSpoolTooSmall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := newSpool(dir, 3); err == nil {
+		t.Error("expected an error for a spool of 3 bytes")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+)
+
+// transportConfig holds the gRPC settings applied to both the
+// connection to the target and the connections to the collectors.
+type transportConfig struct {
+	// keepaliveTime is the interval of the keepalive pings sent when
+	// the connection is idle, 0 disables keepalives.
+	keepaliveTime time.Duration
+	// keepaliveTimeout is how long to wait for a keepalive ping to be
+	// acknowledged before closing the connection.
+	keepaliveTimeout time.Duration
+	// maxSendMsgSize and maxRecvMsgSize are the maximum sizes of the
+	// messages sent and received, 0 uses the gRPC default.
+	maxSendMsgSize int
+	maxRecvMsgSize int
+	// compression is "gzip" or empty for no compression.
+	compression string
+}
+
+func (c *transportConfig) validate() error {
+	if c.keepaliveTime < 0 {
+		return fmt.Errorf("keepalive time must not be negative, got %s", c.keepaliveTime)
+	}
+	if c.keepaliveTimeout < 0 {
+		return fmt.Errorf("keepalive timeout must not be negative, got %s",
+			c.keepaliveTimeout)
+	}
+	if c.maxSendMsgSize < 0 {
+		return fmt.Errorf("max send message size must not be negative, got %d",
+			c.maxSendMsgSize)
+	}
+	if c.maxRecvMsgSize < 0 {
+		return fmt.Errorf("max receive message size must not be negative, got %d",
+			c.maxRecvMsgSize)
+	}
+	switch c.compression {
+	case "", gzip.Name:
+	default:
+		return fmt.Errorf("unsupported compression: %q", c.compression)
+	}
+	return nil
+}
+
+// dialOptions returns the grpc.DialOptions that apply c.
+func (c *transportConfig) dialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if c.keepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    c.keepaliveTime,
+			Timeout: c.keepaliveTimeout,
+			// The Subscribe and Publish streams are long lived, keep
+			// the connection alive while they are being established
+			// too.
+			PermitWithoutStream: true,
+		}))
+	}
+	var callOpts []grpc.CallOption
+	if c.maxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(c.maxSendMsgSize))
+	}
+	if c.maxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(c.maxRecvMsgSize))
+	}
+	if c.compression != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(c.compression))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	return opts
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransportConfig(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg     transportConfig
+		error   bool
+		options int
+	}{
+		"default": {},
+		"all": {
+			cfg: transportConfig{
+				keepaliveTime:    30 * time.Second,
+				keepaliveTimeout: 10 * time.Second,
+				maxSendMsgSize:   16 << 20,
+				maxRecvMsgSize:   16 << 20,
+				compression:      "gzip",
+			},
+			// keepalive params and default call options
+			options: 2,
+		},
+		"negative_keepalive": {
+			cfg:   transportConfig{keepaliveTime: -time.Second},
+			error: true,
+		},
+		"negative_msg_size": {
+			cfg:   transportConfig{maxRecvMsgSize: -1},
+			error: true,
+		},
+		"bad_compression": {
+			cfg:   transportConfig{compression: "zstd"},
+			error: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.cfg.validate()
+			if err != nil {
+				if !tc.error {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			} else if tc.error {
+				t.Fatal("expected error and didn't get one")
+			}
+			if n := len(tc.cfg.dialOptions()); n != tc.options {
+				t.Errorf("expected %d dial options, got %d", tc.options, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/aristanetworks/goarista/kafka"
+	kafkagnmi "github.com/aristanetworks/goarista/kafka/gnmi"
+	"github.com/aristanetworks/goarista/kafka/producer"
+
+	"github.com/Shopify/sarama"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// kafkaConsumer produces the responses of a Publish stream to Kafka,
+// in the JSON messages of ockafka.
+type kafkaConsumer struct {
+	p producer.Producer
+}
+
+// newKafkaConsumer returns a kafkaConsumer producing to the topic
+// -kafkatopic of -kafkaaddrs, with source as key and dataset, as
+// ockafka does with the address of each target.
+func newKafkaConsumer(source string) (consumer, error) {
+	encoder := kafkagnmi.NewEncoder(*kafka.Topic, sarama.StringEncoder(source), source)
+	p, err := producer.New(encoder, strings.Split(*kafka.Addresses, ","), nil)
+	if err != nil {
+		return nil, err
+	}
+	p.Start()
+	return &kafkaConsumer{p: p}, nil
+}
+
+func (c *kafkaConsumer) consume(resp *gnmi.SubscribeResponse) error {
+	c.p.Write(resp)
+	return nil
+}
+
+func (c *kafkaConsumer) close() {
+	c.p.Stop()
+}
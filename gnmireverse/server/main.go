@@ -5,19 +5,33 @@
 package main
 
 import (
+	"bufio"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
 
 	gnmilib "github.com/aristanetworks/goarista/gnmi"
 	"github.com/aristanetworks/goarista/gnmireverse"
 
 	"github.com/aristanetworks/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	// Register the gzip compressor so clients can use -compression=gzip
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
 )
 
 func newTLSConfig(clientCertAuth bool, certFile, keyFile, clientCAFile string) (*tls.Config,
@@ -60,8 +74,23 @@ func main() {
 	keyFile := flag.String("keyfile", "", "path to TLS key file")
 	clientCAFile := flag.String("client_cafile", "",
 		"path to TLS CA file to verify client certificate")
+	output := flag.String("output", "text", "Output of the SubscribeResponses:\n"+
+		"  'text' : one line per update to stdout, prefixed with the peer that sent it\n"+
+		"  'json' : one JSON object per SubscribeResponse to stdout\n"+
+		"  'proto' : one protobuf text format SubscribeResponse per line to stdout\n"+
+		"  'kafka' : JSON messages to the Kafka topic -kafkatopic of -kafkaaddrs,\n"+
+		"            keyed by the peer that sent them")
+	keepaliveMinTime := flag.Duration("keepalive_min_time", 10*time.Second,
+		"minimum interval of the gRPC keepalive pings of clients, clients that ping\n"+
+			"more often are disconnected. It must not be greater than -keepalive_time\n"+
+			"of the clients.")
 	flag.Parse()
 
+	newConsumer, err := newConsumerFunc(*output)
+	if err != nil {
+		glog.Fatal(err)
+	}
+
 	var config *tls.Config
 	if *useTLS {
 		var err error
@@ -71,13 +100,8 @@ func main() {
 		}
 	}
 
-	var serverOptions []grpc.ServerOption
-	if config != nil {
-		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(config)))
-	}
-
-	grpcServer := grpc.NewServer(serverOptions...)
-	s := &server{}
+	grpcServer := grpc.NewServer(serverOptions(config, *keepaliveMinTime)...)
+	s := &server{newConsumer: newConsumer}
 	gnmireverse.RegisterGNMIReverseServer(grpcServer, s)
 
 	listener, err := net.Listen("tcp", *addr)
@@ -89,16 +113,194 @@ func main() {
 	}
 }
 
+// serverOptions returns the options of the gRPC server, which uses
+// TLS if tlsConfig isn't nil and accepts keepalive pings from clients
+// every keepaliveMinTime, even on connections without streams, as
+// sent by gnmireverse clients with -keepalive_time.
+func serverOptions(tlsConfig *tls.Config, keepaliveMinTime time.Duration) []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	return opts
+}
+
+// formatter writes resp, received from the peer identified by
+// source, to w.
+type formatter func(w io.Writer, source string, resp *gnmi.SubscribeResponse) error
+
+func newFormatter(name string) (formatter, error) {
+	switch name {
+	case "text":
+		return formatText, nil
+	case "json":
+		return formatJSON, nil
+	case "proto":
+		return formatProto, nil
+	}
+	return nil, fmt.Errorf("unknown output format: %q", name)
+}
+
+func formatText(w io.Writer, source string, resp *gnmi.SubscribeResponse) error {
+	switch r := resp.Response.(type) {
+	case *gnmi.SubscribeResponse_Error:
+		_, err := fmt.Fprintf(w, "<%s> error: %s\n", source, r.Error.Message)
+		return err
+	case *gnmi.SubscribeResponse_SyncResponse:
+		_, err := fmt.Fprintf(w, "<%s> sync_response: %t\n", source, r.SyncResponse)
+		return err
+	case *gnmi.SubscribeResponse_Update:
+		t := time.Unix(0, r.Update.Timestamp).UTC().Format(time.RFC3339Nano)
+		prefix := gnmilib.StrPath(r.Update.Prefix)
+		var target string
+		if t := r.Update.Prefix.GetTarget(); t != "" {
+			target = "(" + t + ") "
+		}
+		for _, update := range r.Update.Update {
+			if _, err := fmt.Fprintf(w, "<%s> [%s] %s%s = %s\n", source, t, target,
+				path.Join(prefix, gnmilib.StrPath(update.Path)),
+				gnmilib.StrUpdateVal(update)); err != nil {
+				return err
+			}
+		}
+		for _, del := range r.Update.Delete {
+			if _, err := fmt.Fprintf(w, "<%s> [%s] %sDeleted %s\n", source, t, target,
+				path.Join(prefix, gnmilib.StrPath(del))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var jsonMarshaler = jsonpb.Marshaler{}
+
+// jsonResponse is a line of the json output format.
+type jsonResponse struct {
+	Source   string          `json:"source"`
+	Response json.RawMessage `json:"response"`
+}
+
+func formatJSON(w io.Writer, source string, resp *gnmi.SubscribeResponse) error {
+	s, err := jsonMarshaler.MarshalToString(resp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(jsonResponse{Source: source, Response: json.RawMessage(s)})
+}
+
+func formatProto(w io.Writer, source string, resp *gnmi.SubscribeResponse) error {
+	_, err := fmt.Fprintf(w, "<%s> %s\n", source, proto.CompactTextString(resp))
+	return err
+}
+
+// streamSource returns a string identifying the client that opened
+// the Publish stream: its remote address and, if it authenticated
+// with a client certificate, the certificate's common name.
+func streamSource(p *peer.Peer) string {
+	if p == nil {
+		return "unknown"
+	}
+	source := p.Addr.String()
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		if certs := tlsInfo.State.PeerCertificates; len(certs) > 0 {
+			if cn := certs[0].Subject.CommonName; cn != "" {
+				source = cn + "@" + source
+			}
+		}
+	}
+	return source
+}
+
+// consumer receives the SubscribeResponses of a Publish stream.
+type consumer interface {
+	consume(resp *gnmi.SubscribeResponse) error
+	// close is called once the stream ended.
+	close()
+}
+
+// newConsumerFunc returns the function creating the consumer of each
+// Publish stream for output, the value of -output.
+func newConsumerFunc(output string) (func(source string) (consumer, error), error) {
+	if output == "kafka" {
+		return newKafkaConsumer, nil
+	}
+	format, err := newFormatter(output)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer{out: bufio.NewWriter(os.Stdout), format: format}
+	return w.newConsumer, nil
+}
+
+// writer writes the responses of all the Publish streams to out.
+type writer struct {
+	// mu protects out. Publish streams from multiple clients are
+	// handled concurrently and their output must not interleave.
+	mu     sync.Mutex
+	out    *bufio.Writer
+	format formatter
+}
+
+func (w *writer) write(source string, resp *gnmi.SubscribeResponse) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.format(w.out, source, resp); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+func (w *writer) newConsumer(source string) (consumer, error) {
+	return &writerConsumer{w: w, source: source}, nil
+}
+
+// writerConsumer writes the responses of the stream from source with
+// the writer w.
+type writerConsumer struct {
+	w      *writer
+	source string
+}
+
+func (c *writerConsumer) consume(resp *gnmi.SubscribeResponse) error {
+	return c.w.write(c.source, resp)
+}
+
+func (c *writerConsumer) close() {}
+
 type server struct {
+	// newConsumer returns the consumer of the stream from source.
+	newConsumer func(source string) (consumer, error)
 }
 
 func (s *server) Publish(stream gnmireverse.GNMIReverse_PublishServer) error {
+	p, _ := peer.FromContext(stream.Context())
+	source := streamSource(p)
+	c, err := s.newConsumer(source)
+	if err != nil {
+		glog.Errorf("can't handle Publish stream from %s: %s", source, err)
+		return err
+	}
+	defer c.close()
+	glog.Infof("started Publish stream from %s", source)
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
+			if err == io.EOF {
+				glog.Infof("Publish stream from %s closed", source)
+				return stream.SendAndClose(&gnmireverse.Empty{})
+			}
+			glog.Infof("Publish stream from %s failed: %s", source, err)
 			return err
 		}
-		if err := gnmilib.LogSubscribeResponse(resp); err != nil {
+		if err := c.consume(resp); err != nil {
 			glog.Error(err)
 		}
 	}
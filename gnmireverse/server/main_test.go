@@ -0,0 +1,249 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/goarista/gnmireverse"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestFormatter(t *testing.T) {
+	resp := &gnmi.SubscribeResponse{
+		Response: &gnmi.SubscribeResponse_Update{
+			Update: &gnmi.Notification{
+				Timestamp: 1,
+				Prefix: &gnmi.Path{
+					Target: "dev1",
+					Elem:   []*gnmi.PathElem{&gnmi.PathElem{Name: "a"}},
+				},
+				Update: []*gnmi.Update{&gnmi.Update{
+					Path: &gnmi.Path{Elem: []*gnmi.PathElem{&gnmi.PathElem{Name: "b"}}},
+					Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: 5}},
+				}},
+				Delete: []*gnmi.Path{
+					&gnmi.Path{Elem: []*gnmi.PathElem{&gnmi.PathElem{Name: "c"}}},
+				},
+			},
+		},
+	}
+	for name, tc := range map[string]struct {
+		resp *gnmi.SubscribeResponse
+		exp  string
+	}{
+		"text": {
+			resp: resp,
+			exp: "<1.2.3.4:5> [1970-01-01T00:00:00.000000001Z] (dev1) /a/b = 5\n" +
+				"<1.2.3.4:5> [1970-01-01T00:00:00.000000001Z] (dev1) Deleted /a/c\n",
+		},
+		"json": {
+			resp: &gnmi.SubscribeResponse{
+				Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true},
+			},
+			exp: `{"source":"1.2.3.4:5","response":{"syncResponse":true}}` + "\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := newFormatter(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := f(&b, "1.2.3.4:5", tc.resp); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tc.exp {
+				t.Errorf("expected %q, got %q", tc.exp, b.String())
+			}
+		})
+	}
+	if _, err := newFormatter("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestFormatJSONSource(t *testing.T) {
+	resp := &gnmi.SubscribeResponse{
+		Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true},
+	}
+	for _, source := range []string{
+		"rtr-été@1.2.3.4:5",
+		"bad\x7f\x01\"name\"@1.2.3.4:5",
+		"<a&b>@[::1]:5",
+	} {
+		var b bytes.Buffer
+		if err := formatJSON(&b, source, resp); err != nil {
+			t.Fatal(err)
+		}
+		var got jsonResponse
+		if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON %q for source %q: %s", b.String(), source, err)
+		}
+		if got.Source != source {
+			t.Errorf("expected source %q, got %q", source, got.Source)
+		}
+		if exp := `{"syncResponse":true}`; string(got.Response) != exp {
+			t.Errorf("expected response %s, got %s", exp, got.Response)
+		}
+	}
+}
+
+// pingServer sends keepalive pings every interval on a connection
+// without streams to a gRPC server with opts, like an idle gnmireverse
+// client, and returns whether the server closed the connection with a
+// GOAWAY.
+func pingServer(t *testing.T, opts []grpc.ServerOption, pings int,
+	interval time.Duration) bool {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(opts...)
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+	framer := http2.NewFramer(conn, conn)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	goAway := make(chan struct{})
+	go func() {
+		for {
+			f, err := framer.ReadFrame()
+			if err != nil {
+				return
+			}
+			if _, ok := f.(*http2.GoAwayFrame); ok {
+				close(goAway)
+				return
+			}
+		}
+	}()
+	for i := 0; i < pings; i++ {
+		select {
+		case <-goAway:
+			return true
+		case <-time.After(interval):
+		}
+		if err := framer.WritePing(false, [8]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-goAway:
+		return true
+	case <-time.After(interval):
+		return false
+	}
+}
+
+func TestKeepaliveEnforcement(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	// The default policy of gRPC only allows a ping every 5 minutes,
+	// when there are streams.
+	if !pingServer(t, nil, 5, interval) {
+		t.Error("expected the default server to reject the pings")
+	}
+	if pingServer(t, serverOptions(nil, interval/2), 5, interval) {
+		t.Errorf("expected the pings every %s to be accepted", interval)
+	}
+	if !pingServer(t, serverOptions(nil, 2*interval), 5, interval) {
+		t.Errorf("expected the pings every %s to be rejected", interval)
+	}
+}
+
+// publishStream is a Publish stream receiving resps.
+type publishStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps []*gnmi.SubscribeResponse
+}
+
+func (s *publishStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *publishStream) Recv() (*gnmi.SubscribeResponse, error) {
+	if len(s.resps) == 0 {
+		return nil, io.EOF
+	}
+	resp := s.resps[0]
+	s.resps = s.resps[1:]
+	return resp, nil
+}
+
+func (s *publishStream) SendAndClose(*gnmireverse.Empty) error {
+	return nil
+}
+
+type testConsumer struct {
+	source string
+	resps  []*gnmi.SubscribeResponse
+	closed bool
+}
+
+func (c *testConsumer) consume(resp *gnmi.SubscribeResponse) error {
+	c.resps = append(c.resps, resp)
+	return nil
+}
+
+func (c *testConsumer) close() {
+	c.closed = true
+}
+
+func TestPublishConsumer(t *testing.T) {
+	var c *testConsumer
+	s := &server{newConsumer: func(source string) (consumer, error) {
+		c = &testConsumer{source: source}
+		return c, nil
+	}}
+	resps := []*gnmi.SubscribeResponse{
+		{Response: &gnmi.SubscribeResponse_Update{Update: &gnmi.Notification{Timestamp: 1}}},
+		{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}},
+	}
+	ctx := peer.NewContext(context.Background(),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5}})
+	stream := &publishStream{ctx: ctx, resps: append([]*gnmi.SubscribeResponse{}, resps...)}
+	if err := s.Publish(stream); err != nil {
+		t.Fatal(err)
+	}
+	if c == nil {
+		t.Fatal("expected a consumer for the stream")
+	}
+	if c.source != "1.2.3.4:5" {
+		t.Errorf("expected the consumer of 1.2.3.4:5, got %q", c.source)
+	}
+	if len(c.resps) != len(resps) {
+		t.Fatalf("expected %d responses, got %d", len(resps), len(c.resps))
+	}
+	for i, resp := range resps {
+		if !proto.Equal(resp, c.resps[i]) {
+			t.Errorf("expected %v, got %v", resp, c.resps[i])
+		}
+	}
+	if !c.closed {
+		t.Error("expected the consumer to be closed with the stream")
+	}
+}
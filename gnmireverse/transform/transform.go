@@ -0,0 +1,293 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// Package transform implements the transformations the gnmireverse
+// client applies to SubscribeResponses received from the target
+// before publishing them to the collectors.
+//
+// The built-in transforms are configured in the client's config file.
+// Other transforms can be added with Register.
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aristanetworks/goarista/gnmi"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Transform modifies SubscribeResponses before they are published.
+type Transform interface {
+	// Transform returns the response to publish in place of resp, or
+	// nil if nothing must be published. It may modify resp.
+	Transform(resp *pb.SubscribeResponse) *pb.SubscribeResponse
+}
+
+// Func adapts a function to the Transform interface.
+type Func func(resp *pb.SubscribeResponse) *pb.SubscribeResponse
+
+// Transform implements the Transform interface.
+func (f Func) Transform(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+	return f(resp)
+}
+
+// Chain is a Transform applying each of its transforms in order.
+type Chain []Transform
+
+// Transform implements the Transform interface.
+func (c Chain) Transform(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+	for _, t := range c {
+		if resp = t.Transform(resp); resp == nil {
+			return nil
+		}
+	}
+	return resp
+}
+
+// Config describes one transform. For example, in YAML:
+//
+//	type: drop
+//	regex: ['/state/counters/in-(unicast|multicast)-pkts$']
+//	prefix: [/system/processes]
+type Config struct {
+	// Type is the name of the transform: drop, keep, set_target,
+	// redact, or a name given to Register.
+	Type string `yaml:"type"`
+
+	// Regex and Prefix select the paths that the drop, keep and
+	// redact transforms apply to. A path is selected if it matches
+	// one of the regular expressions, or if it starts with one of
+	// the prefixes. Paths are matched in their string form with the
+	// prefix of the notification prepended and without origin,
+	// e.g. /interfaces/interface[name=Ethernet1]/state/mtu.
+	Regex  []string `yaml:"regex"`
+	Prefix []string `yaml:"prefix"`
+
+	// Target is the value set_target sets on the target field of the
+	// notifications.
+	Target string `yaml:"target"`
+
+	// Value is the string that redacted values are replaced with,
+	// <redacted> by default.
+	Value string `yaml:"value"`
+
+	// Options holds the settings of transforms added with Register.
+	Options map[string]string `yaml:"options"`
+}
+
+// A Factory returns the Transform described by cfg.
+type Factory func(cfg *Config) (Transform, error)
+
+var factories = map[string]Factory{
+	"drop":       newDrop,
+	"keep":       newKeep,
+	"set_target": newSetTarget,
+	"redact":     newRedact,
+}
+
+// Register makes the transforms returned by f available under typ in
+// the config file. It is meant to be called from an init function and
+// panics if typ is already registered.
+func Register(typ string, f Factory) {
+	if _, ok := factories[typ]; ok {
+		panic(fmt.Sprintf("transform %q is already registered", typ))
+	}
+	factories[typ] = f
+}
+
+// New returns the chain of transforms described by cfgs.
+func New(cfgs []Config) (Chain, error) {
+	var c Chain
+	for i := range cfgs {
+		cfg := &cfgs[i]
+		f, ok := factories[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform type: %q", cfg.Type)
+		}
+		t, err := f(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error in %s transform: %s", cfg.Type, err)
+		}
+		c = append(c, t)
+	}
+	return c, nil
+}
+
+// matcher selects paths by regular expression or prefix.
+type matcher struct {
+	regexps  []*regexp.Regexp
+	prefixes []string
+}
+
+func newMatcher(cfg *Config) (*matcher, error) {
+	if len(cfg.Regex) == 0 && len(cfg.Prefix) == 0 {
+		return nil, fmt.Errorf("at least one regex or prefix must be set")
+	}
+	m := &matcher{}
+	for _, s := range cfg.Regex {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		m.regexps = append(m.regexps, re)
+	}
+	for _, p := range cfg.Prefix {
+		m.prefixes = append(m.prefixes, "/"+strings.Trim(p, "/"))
+	}
+	return m, nil
+}
+
+func (m *matcher) match(p string) bool {
+	for _, re := range m.regexps {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	for _, prefix := range m.prefixes {
+		if hasPathPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPathPrefix returns whether the path p starts with the elements of
+// prefix. A prefix element without keys matches all keys, so
+// /interfaces/interface is a prefix of
+// /interfaces/interface[name=Ethernet1]/state.
+func hasPathPrefix(p, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	if len(p) == len(prefix) {
+		return true
+	}
+	c := p[len(prefix)]
+	return c == '/' || c == '['
+}
+
+// fullPath returns the string form of p with prefix prepended. The
+// strings are joined as they are, since cleaning them would change the
+// key values containing slashes.
+func fullPath(prefix string, p *pb.Path) string {
+	s := gnmi.StrPath(p)
+	if s == "/" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + s
+}
+
+// filter drops the updates and deletes whose path is selected by m, or
+// those whose path isn't selected if keep is true.
+type filter struct {
+	m    *matcher
+	keep bool
+}
+
+func newDrop(cfg *Config) (Transform, error) {
+	m, err := newMatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{m: m}, nil
+}
+
+func newKeep(cfg *Config) (Transform, error) {
+	m, err := newMatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{m: m, keep: true}, nil
+}
+
+// Transform implements the Transform interface. A notification left
+// with no update or delete is not published.
+func (f *filter) Transform(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+	notif := resp.GetUpdate()
+	if notif == nil || len(notif.Update) == 0 && len(notif.Delete) == 0 {
+		return resp
+	}
+	prefix := gnmi.StrPath(notif.Prefix)
+	updates := notif.Update[:0]
+	for _, u := range notif.Update {
+		if f.m.match(fullPath(prefix, u.Path)) == f.keep {
+			updates = append(updates, u)
+		}
+	}
+	notif.Update = updates
+	deletes := notif.Delete[:0]
+	for _, d := range notif.Delete {
+		if f.m.match(fullPath(prefix, d)) == f.keep {
+			deletes = append(deletes, d)
+		}
+	}
+	notif.Delete = deletes
+	if len(notif.Update) == 0 && len(notif.Delete) == 0 {
+		return nil
+	}
+	return resp
+}
+
+// setTarget sets the target field of the notifications.
+type setTarget struct {
+	target string
+}
+
+func newSetTarget(cfg *Config) (Transform, error) {
+	return &setTarget{target: cfg.Target}, nil
+}
+
+// Transform implements the Transform interface.
+func (t *setTarget) Transform(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+	notif := resp.GetUpdate()
+	if notif == nil {
+		return resp
+	}
+	if notif.Prefix == nil {
+		notif.Prefix = &pb.Path{}
+	}
+	notif.Prefix.Target = t.target
+	return resp
+}
+
+// redact replaces the values of the updates whose path is selected by
+// m.
+type redact struct {
+	m     *matcher
+	value string
+}
+
+func newRedact(cfg *Config) (Transform, error) {
+	m, err := newMatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &redact{m: m, value: cfg.Value}
+	if r.value == "" {
+		r.value = "<redacted>"
+	}
+	return r, nil
+}
+
+// Transform implements the Transform interface.
+func (r *redact) Transform(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+	notif := resp.GetUpdate()
+	if notif == nil {
+		return resp
+	}
+	prefix := gnmi.StrPath(notif.Prefix)
+	for _, u := range notif.Update {
+		if r.m.match(fullPath(prefix, u.Path)) {
+			u.Val = &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: r.value}}
+			u.Value = nil
+		}
+	}
+	return resp
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package transform
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/gnmi"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func parsePath(t *testing.T, s string) *pb.Path {
+	p, err := gnmi.ParseGNMIElements(gnmi.SplitPath(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Element = nil
+	return p
+}
+
+// notification returns a SubscribeResponse with prefix, and an update
+// with a string value for each of the paths. Paths starting with a -
+// are deletes.
+func notification(t *testing.T, prefix string, paths ...string) *pb.SubscribeResponse {
+	notif := &pb.Notification{Timestamp: 1}
+	if prefix != "" {
+		notif.Prefix = parsePath(t, prefix)
+	}
+	for _, p := range paths {
+		if p[0] == '-' {
+			notif.Delete = append(notif.Delete, parsePath(t, p[1:]))
+			continue
+		}
+		notif.Update = append(notif.Update, &pb.Update{
+			Path: parsePath(t, p),
+			Val:  &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "v"}},
+		})
+	}
+	return &pb.SubscribeResponse{Response: &pb.SubscribeResponse_Update{Update: notif}}
+}
+
+var syncResponse = &pb.SubscribeResponse{
+	Response: &pb.SubscribeResponse_SyncResponse{SyncResponse: true},
+}
+
+func TestTransforms(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfgs     []Config
+		in       *pb.SubscribeResponse
+		expected *pb.SubscribeResponse
+	}{
+		"no_transform": {
+			in:       notification(t, "", "/a/b"),
+			expected: notification(t, "", "/a/b"),
+		},
+		"drop_regex": {
+			cfgs:     []Config{{Type: "drop", Regex: []string{"/counters/in-.*-pkts$"}}},
+			in:       notification(t, "", "/a/counters/in-unicast-pkts", "/a/counters/out"),
+			expected: notification(t, "", "/a/counters/out"),
+		},
+		"drop_prefix": {
+			cfgs: []Config{{Type: "drop", Prefix: []string{"/interfaces/interface"}}},
+			in: notification(t, "/interfaces",
+				"/interface[name=Ethernet1]/state", "/interfaces/state", "-/interface"),
+			expected: notification(t, "/interfaces", "/interfaces/state"),
+		},
+		"drop_prefix_element_boundary": {
+			cfgs:     []Config{{Type: "drop", Prefix: []string{"/a/b/"}}},
+			in:       notification(t, "", "/a/b/c", "/a/bc"),
+			expected: notification(t, "", "/a/bc"),
+		},
+		"drop_all": {
+			cfgs: []Config{{Type: "drop", Prefix: []string{"/"}}},
+			in:   notification(t, "", "/a/b", "-/a/c"),
+		},
+		"drop_sync_response": {
+			cfgs:     []Config{{Type: "drop", Prefix: []string{"/"}}},
+			in:       syncResponse,
+			expected: syncResponse,
+		},
+		"keep": {
+			cfgs: []Config{{Type: "keep", Regex: []string{"^/system/state/"},
+				Prefix: []string{"/interfaces"}}},
+			in: notification(t, "", "/system/state/hostname", "/system/processes",
+				"/interfaces/interface[name=Ethernet1]/state", "-/system/cpus"),
+			expected: notification(t, "", "/system/state/hostname",
+				"/interfaces/interface[name=Ethernet1]/state"),
+		},
+		"set_target": {
+			cfgs:     []Config{{Type: "set_target", Target: "dev1"}},
+			in:       notification(t, "", "/a"),
+			expected: withTarget(notification(t, "", "/a"), "dev1"),
+		},
+		"redact": {
+			cfgs:     []Config{{Type: "redact", Regex: []string{"/password$"}}},
+			in:       notification(t, "/system/aaa", "/user[name=admin]/password", "/b"),
+			expected: redacted(notification(t, "/system/aaa", "/user[name=admin]/password", "/b")),
+		},
+		"redact_key_with_slashes": {
+			cfgs: []Config{{Type: "redact",
+				Regex: []string{`^/system/aaa/user\[name=a//b\]/password$`}}},
+			in: notification(t, "/system/aaa", "/user[name=a//b]/password",
+				"/user[name=a/b]/password"),
+			expected: redacted(notification(t, "/system/aaa", "/user[name=a//b]/password",
+				"/user[name=a/b]/password")),
+		},
+		"chain": {
+			cfgs: []Config{
+				{Type: "drop", Prefix: []string{"/a"}},
+				{Type: "set_target", Target: "dev1"},
+			},
+			in:       notification(t, "", "/a/b", "/c"),
+			expected: withTarget(notification(t, "", "/c"), "dev1"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(tc.cfgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := c.Transform(tc.in)
+			if !proto.Equal(tc.expected, out) {
+				t.Errorf("expected %v, got %v", tc.expected, out)
+			}
+		})
+	}
+}
+
+func withTarget(resp *pb.SubscribeResponse, target string) *pb.SubscribeResponse {
+	resp.GetUpdate().Prefix = &pb.Path{Target: target}
+	return resp
+}
+
+func redacted(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+	resp.GetUpdate().Update[0].Val = &pb.TypedValue{
+		Value: &pb.TypedValue_StringVal{StringVal: "<redacted>"}}
+	return resp
+}
+
+func TestNewErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"unknown_type": {Type: "foo"},
+		"no_paths":     {Type: "drop"},
+		"bad_regex":    {Type: "redact", Regex: []string{"("}},
+	} {
+		if _, err := New([]Config{cfg}); err == nil {
+			t.Errorf("%s: expected error and didn't get one", name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_set_timestamp", func(cfg *Config) (Transform, error) {
+		return Func(func(resp *pb.SubscribeResponse) *pb.SubscribeResponse {
+			if notif := resp.GetUpdate(); notif != nil {
+				notif.Timestamp = 42
+			}
+			return resp
+		}), nil
+	})
+	// Unregister it, so that the test can run again in the same process
+	defer delete(factories, "test_set_timestamp")
+	c, err := New([]Config{{Type: "test_set_timestamp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := c.Transform(notification(t, "", "/a")).GetUpdate().Timestamp; ts != 42 {
+		t.Errorf("expected timestamp 42, got %d", ts)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package avro
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The functions below append the Avro binary encoding of a value to b,
+// see https://avro.apache.org/docs/1.9.2/spec.html#binary_encoding
+
+func appendLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendBoolean(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func appendDouble(b []byte, v float64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	return append(b, buf[:]...)
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	b = appendLong(b, int64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, v string) []byte {
+	b = appendLong(b, int64(len(v)))
+	return append(b, v...)
+}
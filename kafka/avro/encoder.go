@@ -0,0 +1,210 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// Package avro implements a kafka.MessageEncoder producing Avro records
+// in the wire format of the Confluent serializers, with their schema
+// registered in a Confluent Schema Registry.
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aristanetworks/goarista/gnmi"
+	"github.com/aristanetworks/goarista/kafka"
+
+	"github.com/Shopify/sarama"
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// RecordName is the fully-qualified name of the record in Schema
+const RecordName = "com.arista.gnmi.Update"
+
+// Schema is the Avro schema of the messages. Each message holds one
+// update or delete of a gNMI notification. Values which have no Avro
+// equivalent, such as JSON or Decimal64 values, are converted to a
+// string or a double.
+const Schema = `{
+  "type": "record",
+  "name": "Update",
+  "namespace": "com.arista.gnmi",
+  "fields": [
+    {"name": "timestamp", "type": "long", "doc": "nanoseconds since the epoch"},
+    {"name": "dataset", "type": "string"},
+    {"name": "target", "type": "string"},
+    {"name": "path", "type": "string"},
+    {"name": "delete", "type": "boolean"},
+    {"name": "value", "type": [
+      "null", "boolean", "long", "double", "string", "bytes",
+      {"type": "array", "items": "string"}
+    ]}
+  ]
+}`
+
+// Indexes of the branches of the value union in Schema
+const (
+	nullBranch = iota
+	booleanBranch
+	longBranch
+	doubleBranch
+	stringBranch
+	bytesBranch
+	arrayBranch
+)
+
+// magicByte starts the messages in the Confluent wire format, followed
+// by the schema ID as a 4-byte big-endian integer.
+const magicByte = 0
+
+type avroMessageEncoder struct {
+	*kafka.BaseEncoder
+	topic    string
+	dataset  string
+	key      sarama.Encoder
+	schemaID int
+}
+
+// NewEncoder creates and returns a new Avro MessageEncoder. The ID of
+// Schema is looked up in registry under the subject given by strategy,
+// after registering it if autoRegister is true.
+func NewEncoder(topic string, key sarama.Encoder, dataset string, registry *Registry,
+	strategy SubjectNameStrategy, autoRegister bool) (kafka.MessageEncoder, error) {
+	subject, err := strategy.Subject(topic, RecordName)
+	if err != nil {
+		return nil, err
+	}
+	var id int
+	if autoRegister {
+		id, err = registry.Register(subject, Schema)
+	} else {
+		id, err = registry.Lookup(subject, Schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kafka.Log.V(1).Infof("Using schema ID %d of subject %q", id, subject)
+	return &avroMessageEncoder{
+		BaseEncoder: kafka.NewBaseEncoder("avro"),
+		topic:       topic,
+		dataset:     dataset,
+		key:         key,
+		schemaID:    id,
+	}, nil
+}
+
+// Encode encodes each update and delete of the notification of a
+// gnmi.SubscribeResponse in its own message. Other responses, such as
+// sync responses, don't produce any message.
+func (e *avroMessageEncoder) Encode(message proto.Message) ([]*sarama.ProducerMessage,
+	error) {
+	response, ok := message.(*pb.SubscribeResponse)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected type %T in proto message: %#v", message, message)
+	}
+	notif := response.GetUpdate()
+	if notif == nil {
+		return nil, nil
+	}
+	prefix := notif.Prefix
+	if prefix == nil {
+		prefix = &pb.Path{}
+	}
+	metadata := kafka.Metadata{StartTime: time.Unix(0, notif.Timestamp), NumMessages: 1}
+	messages := make([]*sarama.ProducerMessage, 0, len(notif.Delete)+len(notif.Update))
+	for _, del := range notif.Delete {
+		messages = append(messages, &sarama.ProducerMessage{
+			Topic:    e.topic,
+			Key:      e.key,
+			Value:    sarama.ByteEncoder(e.encode(notif, gnmi.JoinPaths(prefix, del), nil)),
+			Metadata: metadata,
+		})
+	}
+	for _, update := range notif.Update {
+		path := gnmi.JoinPaths(prefix, update.Path)
+		messages = append(messages, &sarama.ProducerMessage{
+			Topic:    e.topic,
+			Key:      e.key,
+			Value:    sarama.ByteEncoder(e.encode(notif, path, update)),
+			Metadata: metadata,
+		})
+	}
+	return messages, nil
+}
+
+// encode returns the message for the update of path in notif, or
+// for the delete of path if update is nil.
+func (e *avroMessageEncoder) encode(notif *pb.Notification, path *pb.Path,
+	update *pb.Update) []byte {
+	b := make([]byte, 5, 64)
+	b[0] = magicByte
+	binary.BigEndian.PutUint32(b[1:], uint32(e.schemaID))
+
+	b = appendLong(b, notif.Timestamp)
+	b = appendString(b, e.dataset)
+	b = appendString(b, notif.GetPrefix().GetTarget())
+	b = appendString(b, gnmi.StrPath(path))
+	b = appendBoolean(b, update == nil)
+	b = appendValue(b, update.GetVal())
+	kafka.Log.V(9).Infof("Encoded message: %q", b)
+	return b
+}
+
+// appendValue appends the value union of Schema holding val.
+func appendValue(b []byte, val *pb.TypedValue) []byte {
+	switch v := val.GetValue().(type) {
+	case *pb.TypedValue_BoolVal:
+		b = appendLong(b, booleanBranch)
+		return appendBoolean(b, v.BoolVal)
+	case *pb.TypedValue_IntVal:
+		b = appendLong(b, longBranch)
+		return appendLong(b, v.IntVal)
+	case *pb.TypedValue_UintVal:
+		if v.UintVal > math.MaxInt64 {
+			b = appendLong(b, doubleBranch)
+			return appendDouble(b, float64(v.UintVal))
+		}
+		b = appendLong(b, longBranch)
+		return appendLong(b, int64(v.UintVal))
+	case *pb.TypedValue_FloatVal:
+		b = appendLong(b, doubleBranch)
+		return appendDouble(b, float64(v.FloatVal))
+	case *pb.TypedValue_DecimalVal:
+		b = appendLong(b, doubleBranch)
+		return appendDouble(b, gnmi.DecimalToFloat(v.DecimalVal))
+	case *pb.TypedValue_StringVal:
+		b = appendLong(b, stringBranch)
+		return appendString(b, v.StringVal)
+	case *pb.TypedValue_AsciiVal:
+		b = appendLong(b, stringBranch)
+		return appendString(b, v.AsciiVal)
+	case *pb.TypedValue_JsonVal:
+		b = appendLong(b, stringBranch)
+		return appendBytes(b, v.JsonVal)
+	case *pb.TypedValue_JsonIetfVal:
+		b = appendLong(b, stringBranch)
+		return appendBytes(b, v.JsonIetfVal)
+	case *pb.TypedValue_BytesVal:
+		b = appendLong(b, bytesBranch)
+		return appendBytes(b, v.BytesVal)
+	case *pb.TypedValue_LeaflistVal:
+		b = appendLong(b, arrayBranch)
+		elems := v.LeaflistVal.GetElement()
+		if len(elems) > 0 {
+			b = appendLong(b, int64(len(elems)))
+			for _, elem := range elems {
+				b = appendString(b, gnmi.StrVal(elem))
+			}
+		}
+		// Arrays end with a block of zero items
+		return appendLong(b, 0)
+	case nil:
+		return appendLong(b, nullBranch)
+	default:
+		b = appendLong(b, stringBranch)
+		return appendString(b, gnmi.StrVal(val))
+	}
+}
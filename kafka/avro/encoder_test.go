@@ -0,0 +1,207 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package avro
+
+import (
+	"encoding/binary"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aristanetworks/goarista/test"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// decoder decodes the records of Schema.
+type decoder struct {
+	t *testing.T
+	b []byte
+}
+
+func (d *decoder) long() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.t.Fatalf("invalid long in %q", d.b)
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.long()
+	if n < 0 || int64(len(d.b)) < n {
+		d.t.Fatalf("invalid length %d", n)
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) value() interface{} {
+	switch branch := d.long(); branch {
+	case nullBranch:
+		return nil
+	case booleanBranch:
+		v := d.b[0] != 0
+		d.b = d.b[1:]
+		return v
+	case longBranch:
+		return d.long()
+	case doubleBranch:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.b))
+		d.b = d.b[8:]
+		return v
+	case stringBranch:
+		return d.string()
+	case bytesBranch:
+		return d.bytes()
+	case arrayBranch:
+		var v []string
+		for n := d.long(); n != 0; n = d.long() {
+			for i := int64(0); i < n; i++ {
+				v = append(v, d.string())
+			}
+		}
+		return v
+	default:
+		d.t.Fatalf("invalid union branch %d", branch)
+		return nil
+	}
+}
+
+type record struct {
+	schemaID  uint32
+	timestamp int64
+	dataset   string
+	target    string
+	path      string
+	delete    bool
+	value     interface{}
+}
+
+func decode(t *testing.T, b []byte) record {
+	if len(b) < 5 || b[0] != magicByte {
+		t.Fatalf("invalid message header in %q", b)
+	}
+	d := &decoder{t: t, b: b[5:]}
+	r := record{
+		schemaID:  binary.BigEndian.Uint32(b[1:]),
+		timestamp: d.long(),
+		dataset:   d.string(),
+		target:    d.string(),
+		path:      d.string(),
+	}
+	r.delete = d.b[0] != 0
+	d.b = d.b[1:]
+	r.value = d.value()
+	if len(d.b) != 0 {
+		t.Fatalf("trailing bytes %q", d.b)
+	}
+	return r
+}
+
+func update(name string, val *gnmi.TypedValue) *gnmi.Update {
+	return &gnmi.Update{
+		Path: &gnmi.Path{Elem: []*gnmi.PathElem{{Name: name}}},
+		Val:  val,
+	}
+}
+
+func TestEncode(t *testing.T) {
+	f := newFakeRegistry()
+	s := httptest.NewServer(f)
+	defer s.Close()
+	registry := NewRegistry(s.URL, nil)
+
+	if _, err := NewEncoder("foo", nil, "dev1", registry, TopicNameStrategy,
+		false); err == nil {
+		t.Fatal("expected an error for an unregistered schema")
+	}
+	e, err := NewEncoder("foo", nil, "dev1", registry, TopicNameStrategy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.subjects["foo-value"] != Schema {
+		t.Fatalf("schema not registered: %v", f.subjects)
+	}
+
+	notif := &gnmi.Notification{
+		Timestamp: 42,
+		Prefix: &gnmi.Path{
+			Target: "dev1",
+			Elem:   []*gnmi.PathElem{{Name: "a", Key: map[string]string{"k": "v"}}},
+		},
+		Delete: []*gnmi.Path{{Elem: []*gnmi.PathElem{{Name: "gone"}}}},
+		Update: []*gnmi.Update{
+			update("bool", &gnmi.TypedValue{Value: &gnmi.TypedValue_BoolVal{BoolVal: true}}),
+			update("int", &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: -7}}),
+			update("uint", &gnmi.TypedValue{
+				Value: &gnmi.TypedValue_UintVal{UintVal: math.MaxUint64}}),
+			update("decimal", &gnmi.TypedValue{Value: &gnmi.TypedValue_DecimalVal{
+				DecimalVal: &gnmi.Decimal64{Digits: 1234, Precision: 2}}}),
+			update("string", &gnmi.TypedValue{
+				Value: &gnmi.TypedValue_StringVal{StringVal: "foo"}}),
+			update("json", &gnmi.TypedValue{
+				Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"b":1}`)}}),
+			update("bytes", &gnmi.TypedValue{
+				Value: &gnmi.TypedValue_BytesVal{BytesVal: []byte{0, 1}}}),
+			update("leaflist", &gnmi.TypedValue{Value: &gnmi.TypedValue_LeaflistVal{
+				LeaflistVal: &gnmi.ScalarArray{Element: []*gnmi.TypedValue{
+					{Value: &gnmi.TypedValue_StringVal{StringVal: "x"}},
+					{Value: &gnmi.TypedValue_IntVal{IntVal: 2}},
+				}}}}),
+			update("null", nil),
+		},
+	}
+	messages, err := e.Encode(&gnmi.SubscribeResponse{
+		Response: &gnmi.SubscribeResponse_Update{Update: notif}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := uint32(len("foo-value"))
+	rec := func(path string, value interface{}) record {
+		return record{schemaID: id, timestamp: 42, dataset: "dev1", target: "dev1",
+			path: "/a[k=v]/" + path, value: value}
+	}
+	expected := []record{
+		{schemaID: id, timestamp: 42, dataset: "dev1", target: "dev1",
+			path: "/a[k=v]/gone", delete: true},
+		rec("bool", true),
+		rec("int", int64(-7)),
+		rec("uint", float64(math.MaxUint64)),
+		rec("decimal", 12.34),
+		rec("string", "foo"),
+		rec("json", `{"b":1}`),
+		rec("bytes", []byte{0, 1}),
+		rec("leaflist", []string{"x", "2"}),
+		rec("null", nil),
+	}
+	got := make([]record, len(messages))
+	for i, m := range messages {
+		if m.Topic != "foo" {
+			t.Errorf("unexpected topic %q", m.Topic)
+		}
+		b, err := m.Value.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[i] = decode(t, b)
+	}
+	if !test.DeepEqual(expected, got) {
+		t.Errorf("unexpected records: %s", test.Diff(expected, got))
+	}
+
+	messages, err = e.Encode(&gnmi.SubscribeResponse{
+		Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	if err != nil || len(messages) != 0 {
+		t.Errorf("expected no messages for a sync response, got %v, %v", messages, err)
+	}
+}
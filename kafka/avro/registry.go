@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// contentType is the media type of the requests to the Schema Registry
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// SubjectNameStrategy determines the subject under which the schema of
+// the messages of a topic is registered.
+type SubjectNameStrategy string
+
+// The subject name strategies of the Confluent serializers
+const (
+	// TopicNameStrategy uses <topic>-value as the subject
+	TopicNameStrategy SubjectNameStrategy = "topic"
+	// RecordNameStrategy uses the fully-qualified record name as the subject
+	RecordNameStrategy SubjectNameStrategy = "record"
+	// TopicRecordNameStrategy uses <topic>-<fully-qualified record name>
+	// as the subject
+	TopicRecordNameStrategy SubjectNameStrategy = "topic_record"
+)
+
+// Subject returns the subject of the schema of the record named record
+// for the messages of topic.
+func (s SubjectNameStrategy) Subject(topic, record string) (string, error) {
+	switch s {
+	case TopicNameStrategy:
+		return topic + "-value", nil
+	case RecordNameStrategy:
+		return record, nil
+	case TopicRecordNameStrategy:
+		return topic + "-" + record, nil
+	}
+	return "", fmt.Errorf("unknown subject name strategy %q", string(s))
+}
+
+// Registry is a client of the Confluent Schema Registry REST API.
+type Registry struct {
+	url    string
+	client *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int // Schema IDs by subject
+}
+
+// NewRegistry returns a client of the Schema Registry at url. If client
+// is nil, http.DefaultClient is used.
+func NewRegistry(url string, client *http.Client) *Registry {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Registry{
+		url:    strings.TrimSuffix(url, "/"),
+		client: client,
+		ids:    make(map[string]int),
+	}
+}
+
+type schemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+type schemaResponse struct {
+	ID int `json:"id"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// Register registers schema under subject, unless it already is, and
+// returns its ID.
+func (r *Registry) Register(subject, schema string) (int, error) {
+	return r.schemaID("/subjects/"+url.PathEscape(subject)+"/versions", subject, schema)
+}
+
+// Lookup returns the ID of schema, which must already be registered
+// under subject.
+func (r *Registry) Lookup(subject, schema string) (int, error) {
+	return r.schemaID("/subjects/"+url.PathEscape(subject), subject, schema)
+}
+
+func (r *Registry) schemaID(path, subject, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.ids[subject+"\x00"+schema]; ok {
+		return id, nil
+	}
+	body, err := json.Marshal(schemaRequest{Schema: schema})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Post(r.url+path, contentType, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query schema registry: %s", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema registry response: %s", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if err := json.Unmarshal(b, &e); err != nil || e.Message == "" {
+			return 0, fmt.Errorf("schema registry returned %s for subject %q",
+				resp.Status, subject)
+		}
+		return 0, fmt.Errorf("schema registry returned error %d for subject %q: %s",
+			e.ErrorCode, subject, e.Message)
+	}
+	var s schemaResponse
+	if err := json.Unmarshal(b, &s); err != nil {
+		return 0, fmt.Errorf("failed to decode schema registry response: %s", err)
+	}
+	r.ids[subject+"\x00"+schema] = s.ID
+	return s.ID, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is a Schema Registry holding one schema per subject.
+type fakeRegistry struct {
+	mu       sync.Mutex
+	subjects map[string]string
+	requests []string
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{subjects: make(map[string]string)}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.EscapedPath())
+	var req schemaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/subjects/")
+	subject := strings.TrimSuffix(path, "/versions")
+	if subject != path {
+		// Registration
+		f.subjects[subject] = req.Schema
+	} else if f.subjects[subject] != req.Schema {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error_code":40403,"message":"Schema not found"}`)
+		return
+	}
+	fmt.Fprintf(w, `{"id":%d}`, len(subject))
+}
+
+func TestSubjectNameStrategy(t *testing.T) {
+	for name, tc := range map[string]struct {
+		strategy SubjectNameStrategy
+		subject  string
+		error    bool
+	}{
+		"topic": {
+			strategy: TopicNameStrategy,
+			subject:  "foo-value",
+		},
+		"record": {
+			strategy: RecordNameStrategy,
+			subject:  RecordName,
+		},
+		"topic_record": {
+			strategy: TopicRecordNameStrategy,
+			subject:  "foo-" + RecordName,
+		},
+		"unknown": {
+			strategy: "bar",
+			error:    true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			subject, err := tc.strategy.Subject("foo", RecordName)
+			if err != nil {
+				if !tc.error {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			} else if tc.error {
+				t.Fatal("expected error and didn't get one")
+			}
+			if subject != tc.subject {
+				t.Errorf("expected subject %q, got %q", tc.subject, subject)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	f := newFakeRegistry()
+	s := httptest.NewServer(f)
+	defer s.Close()
+	r := NewRegistry(s.URL+"/", nil)
+
+	if _, err := r.Lookup("foo-value", Schema); err == nil ||
+		!strings.Contains(err.Error(), "Schema not found") {
+		t.Errorf("expected a schema not found error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		id, err := r.Register("foo-value", Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != len("foo-value") {
+			t.Errorf("unexpected schema ID %d", id)
+		}
+	}
+	id, err := r.Lookup("foo-value", Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != len("foo-value") {
+		t.Errorf("unexpected schema ID %d", id)
+	}
+	// The ID is cached after the first successful request
+	expected := []string{
+		"POST /subjects/foo-value",
+		"POST /subjects/foo-value/versions",
+	}
+	if fmt.Sprint(expected) != fmt.Sprint(f.requests) {
+		t.Errorf("expected requests %q, got %q", expected, f.requests)
+	}
+}
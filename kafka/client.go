@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/Shopify/sarama"
-	"github.com/aristanetworks/glog"
 )
 
 const (
@@ -34,7 +33,7 @@ func NewClient(addresses []string) (sarama.Client, error) {
 		client, err = sarama.NewClient(addresses, config)
 		retries--
 		if err == sarama.ErrOutOfBrokers {
-			glog.Errorf("Can't connect to the Kafka cluster at %s (%d retries left): %s",
+			Log.Errorf("Can't connect to the Kafka cluster at %s (%d retries left): %s",
 				addresses, retries, err)
 			time.Sleep(outOfBrokersBackoff)
 		} else {
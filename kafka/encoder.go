@@ -13,7 +13,6 @@ import (
 	"github.com/aristanetworks/goarista/monitor"
 
 	"github.com/Shopify/sarama"
-	"github.com/aristanetworks/glog"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -89,6 +88,6 @@ func (e *BaseEncoder) HandleError(msg *sarama.ProducerError) {
 	}
 	// TODO: Add a monotonic clock source when one becomes available
 	e.histogram.UpdateLatencyValues(time.Since(metadata.StartTime))
-	glog.Errorf("Kafka Producer error: %s", msg.Error())
+	Log.Errorf("Producer error: %s", msg.Error())
 	e.numFailures.Add(uint64(metadata.NumMessages))
 }
@@ -8,6 +8,8 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+
+	"github.com/aristanetworks/goarista/logger"
 )
 
 // Addresses is the flag for kafka's comma-separated addresses
@@ -15,3 +17,7 @@ var Addresses = flag.String("kafkaaddrs", "localhost:9092", "kafka's comma-separ
 
 // Topic is the flag for kafka's topic
 var Topic = flag.String("kafkatopic", filepath.Base(os.Args[0]), "kafka's topic")
+
+// Log is the logger of the kafka producer and encoders, whose V level
+// can be set with -vsubsystem=kafka=N
+var Log = logger.New("kafka")
@@ -13,7 +13,6 @@ import (
 	"github.com/aristanetworks/goarista/kafka"
 
 	"github.com/Shopify/sarama"
-	"github.com/aristanetworks/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/openconfig/gnmi/proto/gnmi"
 )
@@ -43,7 +42,11 @@ type elasticsearchMessageEncoder struct {
 	key     sarama.Encoder
 }
 
-// NewEncoder creates and returns a new elasticsearch MessageEncoder
+// NewEncoder creates and returns a new elasticsearch MessageEncoder.
+// Its messages are the documents of elasticsearch.NotificationToMaps,
+// keyed by the path of each update relative to the prefix and holding
+// the value in the field of its type, which the leaves of gnmi/leaves
+// don't keep, so unlike the other exporters it doesn't use gnmi/leaves.
 func NewEncoder(topic string, key sarama.Encoder, dataset string) kafka.MessageEncoder {
 	baseEncoder := kafka.NewBaseEncoder("elasticsearch")
 	return &elasticsearchMessageEncoder{
@@ -74,7 +77,7 @@ func (e *elasticsearchMessageEncoder) Encode(message proto.Message) ([]*sarama.P
 		if err != nil {
 			return nil, err
 		}
-		glog.V(9).Infof("kafka: %s", updateJSON)
+		kafka.Log.V(9).Infof("Encoded message: %s", updateJSON)
 
 		messages[i] = &sarama.ProducerMessage{
 			Topic:    e.topic,
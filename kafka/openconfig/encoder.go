@@ -14,7 +14,6 @@ import (
 	"github.com/aristanetworks/goarista/openconfig"
 
 	"github.com/Shopify/sarama"
-	"github.com/aristanetworks/glog"
 	"github.com/golang/protobuf/proto"
 	pb "github.com/openconfig/reference/rpc/openconfig"
 )
@@ -77,7 +76,7 @@ func (e *elasticsearchMessageEncoder) Encode(message proto.Message) ([]*sarama.P
 	if err != nil {
 		return nil, err
 	}
-	glog.V(9).Infof("kafka: %s", updateJSON)
+	kafka.Log.V(9).Infof("Encoded message: %s", updateJSON)
 	return []*sarama.ProducerMessage{
 		{
 			Topic:    e.topic,
@@ -12,7 +12,6 @@ import (
 	"github.com/aristanetworks/goarista/kafka/gnmi"
 
 	"github.com/Shopify/sarama"
-	"github.com/aristanetworks/glog"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -116,7 +115,7 @@ func (p *producer) produceNotifications(protoMessage proto.Message) error {
 		case <-p.done:
 			return nil
 		case p.kafkaProducer.Input() <- m:
-			glog.V(9).Infof("Message produced to Kafka: %v", m)
+			kafka.Log.V(9).Infof("Message produced to Kafka: %v", m)
 		}
 	}
 	return nil
@@ -0,0 +1,253 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"sync"
+)
+
+// syncMapShards is the number of shards of a SyncMap. It is a power of
+// two so the shard of a key is given by the low bits of its hash.
+const syncMapShards = 32
+
+type syncMapShard struct {
+	mu sync.RWMutex
+	m  Map
+}
+
+// SyncMap is a Map that is safe for concurrent use by multiple
+// goroutines. The entries are spread over shards by the hash of their
+// key, each with its own lock, so that operations on different keys
+// rarely contend. The zero value is an empty SyncMap ready to use.
+type SyncMap struct {
+	shards [syncMapShards]syncMapShard
+}
+
+// NewSyncMap creates a new SyncMap from a list of key-value pairs, so long as the list is
+// of even length.
+func NewSyncMap(keysAndVals ...interface{}) *SyncMap {
+	if len(keysAndVals)%2 != 0 {
+		panic("Odd number of arguments passed to NewSyncMap. Arguments should be of form: " +
+			"key1, value1, key2, value2, ...")
+	}
+	var m SyncMap
+	m.SetMany(keysAndVals...)
+	return &m
+}
+
+// shardIndex returns the index of the shard holding key k.
+func shardIndex(k interface{}) int {
+	var h uint64
+	if hkey, ok := k.(Hashable); ok {
+		h = hkey.Hash()
+	} else {
+		h = uint64(hashInterface(k))
+	}
+	return int(h % syncMapShards)
+}
+
+// shard returns the shard holding key k.
+func (m *SyncMap) shard(k interface{}) *syncMapShard {
+	return &m.shards[shardIndex(k)]
+}
+
+// Len returns the length of the SyncMap
+func (m *SyncMap) Len() int {
+	var length int
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		length += s.m.Len()
+		s.mu.RUnlock()
+	}
+	return length
+}
+
+// Get retrieves the value stored with key k from the SyncMap
+func (m *SyncMap) Get(k interface{}) (interface{}, bool) {
+	if k == nil {
+		return nil, false
+	}
+	s := m.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Get(k)
+}
+
+// Set adds a key-value pair to the SyncMap
+func (m *SyncMap) Set(k, v interface{}) {
+	if k == nil {
+		return
+	}
+	s := m.shard(k)
+	s.mu.Lock()
+	s.m.Set(k, v)
+	s.mu.Unlock()
+}
+
+// Del removes an entry with key k from the SyncMap
+func (m *SyncMap) Del(k interface{}) {
+	if k == nil {
+		return
+	}
+	s := m.shard(k)
+	s.mu.Lock()
+	s.m.Del(k)
+	s.mu.Unlock()
+}
+
+// GetOrCreate returns the value stored with key k if present.
+// Otherwise it stores and returns the value returned by create, which
+// is called at most once, while the shard of k is locked. The boolean
+// result is true if the value was created.
+func (m *SyncMap) GetOrCreate(k interface{}, create func() interface{}) (interface{}, bool) {
+	if k == nil {
+		return nil, false
+	}
+	s := m.shard(k)
+	s.mu.RLock()
+	v, ok := s.m.Get(k)
+	s.mu.RUnlock()
+	if ok {
+		return v, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another goroutine may have created it in the meantime
+	if v, ok := s.m.Get(k); ok {
+		return v, false
+	}
+	v = create()
+	s.m.Set(k, v)
+	return v, true
+}
+
+// SetMany adds a list of key-value pairs to the SyncMap, so long as the list is of even
+// length. Each shard is locked once for all its keys.
+func (m *SyncMap) SetMany(keysAndVals ...interface{}) {
+	if len(keysAndVals)%2 != 0 {
+		panic("Odd number of arguments passed to SetMany. Arguments should be of form: " +
+			"key1, value1, key2, value2, ...")
+	}
+	n := len(keysAndVals) / 2
+	var shardsBuf [shardBufLen]uint8
+	var orderBuf [shardBufLen]int32
+	var shards []uint8
+	var order []int32
+	if n <= shardBufLen {
+		shards, order = shardsBuf[:n], orderBuf[:n]
+	} else {
+		shards, order = make([]uint8, n), make([]int32, n)
+	}
+	for i := range shards {
+		shards[i] = keyShard(keysAndVals[2*i])
+	}
+	var starts [syncMapShards + 1]int32
+	groupByShard(shards, order, &starts)
+	for idx := 0; idx < syncMapShards; idx++ {
+		if starts[idx] == starts[idx+1] {
+			continue
+		}
+		s := &m.shards[idx]
+		s.mu.Lock()
+		for _, i := range order[starts[idx]:starts[idx+1]] {
+			s.m.Set(keysAndVals[2*i], keysAndVals[2*i+1])
+		}
+		s.mu.Unlock()
+	}
+}
+
+// DelMany removes the entries with the given keys from the SyncMap, locking each shard
+// once for all its keys.
+func (m *SyncMap) DelMany(keys ...interface{}) {
+	n := len(keys)
+	var shardsBuf [shardBufLen]uint8
+	var orderBuf [shardBufLen]int32
+	var shards []uint8
+	var order []int32
+	if n <= shardBufLen {
+		shards, order = shardsBuf[:n], orderBuf[:n]
+	} else {
+		shards, order = make([]uint8, n), make([]int32, n)
+	}
+	for i := range shards {
+		shards[i] = keyShard(keys[i])
+	}
+	var starts [syncMapShards + 1]int32
+	groupByShard(shards, order, &starts)
+	for idx := 0; idx < syncMapShards; idx++ {
+		if starts[idx] == starts[idx+1] {
+			continue
+		}
+		s := &m.shards[idx]
+		s.mu.Lock()
+		for _, i := range order[starts[idx]:starts[idx+1]] {
+			s.m.Del(keys[i])
+		}
+		s.mu.Unlock()
+	}
+}
+
+// shardBufLen is the number of keys SetMany and DelMany group by shard
+// without allocating.
+const shardBufLen = 64
+
+// nilShard is the shard of a nil key, which isn't stored in any shard.
+const nilShard = 0xff
+
+// keyShard returns the index of the shard of k, or nilShard if k is
+// nil.
+func keyShard(k interface{}) uint8 {
+	if k == nil {
+		return nilShard
+	}
+	return uint8(shardIndex(k))
+}
+
+// groupByShard sorts the indexes of the keys whose shards are in
+// shards into order, grouped by shard with a counting sort, keeping
+// their order within each shard. The keys of the shard idx are then at
+// order[starts[idx]:starts[idx+1]]. The keys of nilShard are left
+// out.
+func groupByShard(shards []uint8, order []int32, starts *[syncMapShards + 1]int32) {
+	for _, idx := range shards {
+		if idx != nilShard {
+			starts[idx+1]++
+		}
+	}
+	for idx := 1; idx < len(starts); idx++ {
+		starts[idx] += starts[idx-1]
+	}
+	next := *starts
+	for i, idx := range shards {
+		if idx != nilShard {
+			order[next[idx]] = int32(i)
+			next[idx]++
+		}
+	}
+}
+
+// Range calls f sequentially for each key and value in the SyncMap, until f returns
+// false. The entries of each shard are copied before calling f, so f may modify the
+// SyncMap; as with sync.Map, Range doesn't correspond to a consistent snapshot of the
+// whole SyncMap.
+func (m *SyncMap) Range(f func(k, v interface{}) bool) {
+	var kvs []interface{}
+	for i := range m.shards {
+		s := &m.shards[i]
+		kvs = kvs[:0]
+		s.mu.RLock()
+		_ = s.m.Iter(func(k, v interface{}) error {
+			kvs = append(kvs, k, v)
+			return nil
+		})
+		s.mu.RUnlock()
+		for j := 0; j < len(kvs); j += 2 {
+			if !f(kvs[j], kvs[j+1]) {
+				return
+			}
+		}
+	}
+}
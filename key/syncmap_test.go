@@ -0,0 +1,284 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSyncMapSetGetDel(t *testing.T) {
+	m := NewSyncMap(
+		"a", 1,
+		New(map[string]interface{}{"k": 42}), 2,
+		dumbHashable{dumb: "x"}, 3,
+		dumbHashable{dumb: "y"}, 4,
+	)
+	for _, kv := range []struct {
+		k, v interface{}
+	}{
+		{"a", 1},
+		{New(map[string]interface{}{"k": 42}), 2},
+		{dumbHashable{dumb: "x"}, 3},
+		{dumbHashable{dumb: "y"}, 4},
+	} {
+		if v, ok := m.Get(kv.k); !ok || v != kv.v {
+			t.Errorf("Get(%v): expected %v, got %v, %t", kv.k, kv.v, v, ok)
+		}
+	}
+	if m.Len() != 4 {
+		t.Errorf("expected length 4, got %d", m.Len())
+	}
+	if _, ok := m.Get("b"); ok {
+		t.Error("unexpected value for b")
+	}
+	m.Set("a", 5)
+	if v, _ := m.Get("a"); v != 5 {
+		t.Errorf("expected 5, got %v", v)
+	}
+
+	m.Del("a")
+	m.Del(dumbHashable{dumb: "x"})
+	m.Del("b")
+	if m.Len() != 2 {
+		t.Errorf("expected length 2, got %d", m.Len())
+	}
+	if _, ok := m.Get(dumbHashable{dumb: "x"}); ok {
+		t.Error("unexpected value for deleted key")
+	}
+	if v, ok := m.Get(dumbHashable{dumb: "y"}); !ok || v != 4 {
+		t.Errorf("expected 4 for colliding key, got %v, %t", v, ok)
+	}
+}
+
+func TestSyncMapBulk(t *testing.T) {
+	var m SyncMap
+	var keysAndVals, keys []interface{}
+	for i := 0; i < 100; i++ {
+		keysAndVals = append(keysAndVals, i, fmt.Sprint(i))
+		if i%2 == 0 {
+			keys = append(keys, i)
+		}
+	}
+	m.SetMany(keysAndVals...)
+	if m.Len() != 100 {
+		t.Fatalf("expected length 100, got %d", m.Len())
+	}
+	m.DelMany(keys...)
+	if m.Len() != 50 {
+		t.Fatalf("expected length 50, got %d", m.Len())
+	}
+	for i := 0; i < 100; i++ {
+		v, ok := m.Get(i)
+		if ok != (i%2 == 1) {
+			t.Errorf("Get(%d): unexpected presence %t", i, ok)
+		} else if ok && v != fmt.Sprint(i) {
+			t.Errorf("Get(%d): unexpected value %v", i, v)
+		}
+	}
+
+	// The nil keys are skipped and the last value of a key is kept
+	var small SyncMap
+	small.SetMany(nil, 1, "a", 1, "b", 1, "a", 2)
+	if v, ok := small.Get("a"); !ok || v != 2 || small.Len() != 2 {
+		t.Errorf("expected a=2 and a length of 2, got %v, %t, %d", v, ok, small.Len())
+	}
+	small.DelMany(nil, "a")
+	if _, ok := small.Get("a"); ok || small.Len() != 1 {
+		t.Errorf("expected only b, got length %d", small.Len())
+	}
+}
+
+func TestSyncMapRange(t *testing.T) {
+	m := NewSyncMap()
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	seen := make(map[interface{}]bool)
+	m.Range(func(k, v interface{}) bool {
+		if k != v {
+			t.Errorf("unexpected value %v for key %v", v, k)
+		}
+		seen[k] = true
+		// Modifying the map from f doesn't deadlock
+		m.Del(k)
+		return true
+	})
+	if len(seen) != 100 {
+		t.Errorf("expected 100 entries, got %d", len(seen))
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected an empty map, got length %d", m.Len())
+	}
+
+	m.SetMany(1, 1, 2, 2, 3, 3)
+	var n int
+	m.Range(func(k, v interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("expected Range to stop after 1 entry, got %d", n)
+	}
+}
+
+func TestSyncMapGetOrCreate(t *testing.T) {
+	var m SyncMap
+	var creates int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := m.GetOrCreate("a", func() interface{} {
+				atomic.AddInt32(&creates, 1)
+				return 42
+			})
+			if v != 42 {
+				t.Errorf("expected 42, got %v", v)
+			}
+		}()
+	}
+	wg.Wait()
+	if creates != 1 {
+		t.Errorf("expected the value to be created once, got %d", creates)
+	}
+	if _, created := m.GetOrCreate("a", func() interface{} { return 0 }); created {
+		t.Error("unexpected creation of an existing value")
+	}
+}
+
+func TestSyncMapConcurrent(t *testing.T) {
+	var m SyncMap
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := New(map[string]interface{}{"g": g, "i": i})
+				m.Set(k, i)
+				if v, ok := m.Get(k); !ok || v != i {
+					t.Errorf("Get(%v): expected %d, got %v", k, i, v)
+					return
+				}
+				if i%2 == 0 {
+					m.Del(k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if m.Len() != 8*500 {
+		t.Errorf("expected length %d, got %d", 8*500, m.Len())
+	}
+}
+
+// notificationKeys returns the keys of the updates of a notification of
+// interface counters, as used when caching the latest values of paths.
+func notificationKeys(intf int) []interface{} {
+	counters := []string{"in-octets", "out-octets", "in-pkts", "out-pkts",
+		"in-errors", "out-errors", "in-discards", "out-discards"}
+	keys := make([]interface{}, len(counters))
+	for i, c := range counters {
+		keys[i] = fmt.Sprintf("/interfaces/interface[name=Ethernet%d]/state/counters/%s",
+			intf, c)
+	}
+	return keys
+}
+
+func BenchmarkSyncMap(b *testing.B) {
+	const numIntfs = 64
+	notifs := make([][]interface{}, numIntfs)
+	for i := range notifs {
+		notifs[i] = notificationKeys(i)
+	}
+	compositeKeys := make([]interface{}, 150)
+	for j := range compositeKeys {
+		compositeKeys[j] = New(map[string]interface{}{
+			"foobar": 100,
+			"baz":    j,
+		})
+	}
+
+	b.Run("mutex+key.Map/SetNotification", func(b *testing.B) {
+		var mu sync.Mutex
+		m := NewMap()
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			for pb.Next() {
+				mu.Lock()
+				for _, k := range notifs[i%numIntfs] {
+					m.Set(k, i)
+				}
+				mu.Unlock()
+				i++
+			}
+		})
+	})
+	b.Run("key.SyncMap/SetNotification", func(b *testing.B) {
+		var m SyncMap
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			for pb.Next() {
+				for _, k := range notifs[i%numIntfs] {
+					m.Set(k, i)
+				}
+				i++
+			}
+		})
+	})
+	b.Run("key.SyncMap/SetManyNotification", func(b *testing.B) {
+		var m SyncMap
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			kvs := make([]interface{}, 0, 2*len(notifs[0]))
+			for pb.Next() {
+				kvs = kvs[:0]
+				for _, k := range notifs[i%numIntfs] {
+					kvs = append(kvs, k, i)
+				}
+				m.SetMany(kvs...)
+				i++
+			}
+		})
+	})
+	b.Run("mutex+key.Map/GetComposite", func(b *testing.B) {
+		var mu sync.RWMutex
+		m := NewMap()
+		for _, k := range compositeKeys {
+			m.Set(k, true)
+		}
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			for pb.Next() {
+				mu.RLock()
+				_, ok := m.Get(compositeKeys[i%len(compositeKeys)])
+				mu.RUnlock()
+				if !ok {
+					b.Fatal("key not found")
+				}
+				i++
+			}
+		})
+	})
+	b.Run("key.SyncMap/GetComposite", func(b *testing.B) {
+		var m SyncMap
+		for _, k := range compositeKeys {
+			m.Set(k, true)
+		}
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			for pb.Next() {
+				if _, ok := m.Get(compositeKeys[i%len(compositeKeys)]); !ok {
+					b.Fatal("key not found")
+				}
+				i++
+			}
+		})
+	})
+}
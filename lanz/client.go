@@ -11,6 +11,8 @@ import (
 	"encoding/binary"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/aristanetworks/goarista/lanz/proto"
@@ -22,6 +24,7 @@ import (
 const (
 	defaultConnectTimeout = 10 * time.Second
 	defaultConnectBackoff = 30 * time.Second
+	defaultMaxBackoff     = 30 * time.Second
 )
 
 // Client is the LANZ client interface.
@@ -35,6 +38,54 @@ type Client interface {
 	Stop()
 }
 
+// StatsReporter is implemented by the clients returned by New, which count
+// the connections and records.
+type StatsReporter interface {
+	// Stats returns the counters of the client.
+	Stats() Stats
+}
+
+// Stats holds the counters of a client.
+type Stats struct {
+	// Connects is the number of successful connections to the LANZ server.
+	Connects uint64
+	// Parsed is the number of records decoded.
+	Parsed uint64
+	// Dropped is the number of records lost, because they were malformed or
+	// truncated, or because the client was stopped before the receiver read them.
+	Dropped uint64
+}
+
+// ConnectionState is the state of the connection to the LANZ server.
+type ConnectionState int
+
+const (
+	// Disconnected means the client failed to connect or lost its connection.
+	Disconnected ConnectionState = iota
+	// Connected means the client is connected and reading records.
+	Connected
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connected:
+		return "connected"
+	}
+	return "unknown"
+}
+
+// Event is a change of the state of the connection to the LANZ server.
+type Event struct {
+	State ConnectionState
+	// Err is the reason of the disconnection, if any.
+	Err error
+	// Backoff is how long the client waits before reconnecting after a
+	// disconnection.
+	Backoff time.Duration
+}
+
 // ConnectReadCloser extends the io.ReadCloser interface with a Connect method.
 type ConnectReadCloser interface {
 	io.ReadCloser
@@ -43,24 +94,36 @@ type ConnectReadCloser interface {
 }
 
 type client struct {
-	addr     string
-	stopping bool
-	timeout  time.Duration
-	backoff  time.Duration
-	conn     ConnectReadCloser
+	addr       string
+	stopOnce   sync.Once
+	done       chan struct{}
+	timeout    time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+	conn       ConnectReadCloser
+	events     chan<- Event
+
+	// Counters, accessed atomically
+	connects uint64
+	parsed   uint64
+	dropped  uint64
 }
 
 // New creates a new client with default TCP connection to the LANZ server.
 func New(opts ...Option) Client {
 	c := &client{
-		stopping: false,
-		timeout:  defaultConnectTimeout,
-		backoff:  defaultConnectBackoff,
+		done:       make(chan struct{}),
+		timeout:    defaultConnectTimeout,
+		backoff:    defaultConnectBackoff,
+		maxBackoff: defaultMaxBackoff,
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.maxBackoff < c.backoff {
+		c.maxBackoff = c.backoff
+	}
 
 	if c.conn == nil {
 		if c.addr == "" {
@@ -76,56 +139,132 @@ func New(opts ...Option) Client {
 	return c
 }
 
+// Run reconnects to the LANZ server with an exponential backoff: the delay
+// starts at the backoff set with WithBackoff and doubles after each
+// connection that fails or doesn't deliver any record, up to the maximum
+// set with WithMaxBackoff.
 func (c *client) Run(ch chan<- *pb.LanzRecord) {
-	for !c.stopping {
-		if err := c.conn.Connect(); err != nil && !c.stopping {
-			glog.V(1).Infof("Can't connect to LANZ server: %v", err)
-			time.Sleep(c.backoff)
+	backoff := c.backoff
+	for !c.stopped() {
+		if err := c.conn.Connect(); err != nil {
+			if !c.stopped() {
+				glog.V(1).Infof("Can't connect to LANZ server: %v", err)
+				c.notify(Event{State: Disconnected, Err: err, Backoff: backoff})
+				c.sleep(backoff)
+				backoff = c.nextBackoff(backoff)
+			}
 			continue
 		}
+		atomic.AddUint64(&c.connects, 1)
 		glog.V(1).Infof("Connected successfully to LANZ server: %v", c.addr)
-		if err := c.read(bufio.NewReader(c.conn), ch); err != nil && !c.stopping {
+		c.notify(Event{State: Connected})
+		n, err := c.read(bufio.NewReader(c.conn), ch)
+		if n > 0 {
+			// The connection was healthy, start over from the initial backoff
+			backoff = c.backoff
+		}
+		if err != nil && !c.stopped() {
 			if err != io.EOF && err != io.ErrUnexpectedEOF {
 				glog.Errorf("Error receiving LANZ events: %v", err)
 			}
 			c.conn.Close()
-			time.Sleep(c.backoff)
+			c.notify(Event{State: Disconnected, Err: err, Backoff: backoff})
+			c.sleep(backoff)
+			backoff = c.nextBackoff(backoff)
 		}
 	}
 
 	close(ch)
 }
 
-func (c *client) read(r *bufio.Reader, ch chan<- *pb.LanzRecord) error {
-	for !c.stopping {
+func (c *client) nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > c.maxBackoff {
+		return c.maxBackoff
+	}
+	return backoff
+}
+
+// sleep waits for d, or until the client is stopped.
+func (c *client) sleep(d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-c.done:
+	}
+}
+
+// notify sends e to the events channel without blocking, dropping it if
+// the receiver isn't ready.
+func (c *client) notify(e Event) {
+	if c.events == nil {
+		return
+	}
+	select {
+	case c.events <- e:
+	default:
+		glog.V(1).Infof("Dropped LANZ connection event: %s", e.State)
+	}
+}
+
+// read sends the records read from r to ch, and returns how many it sent.
+func (c *client) read(r *bufio.Reader, ch chan<- *pb.LanzRecord) (int, error) {
+	var n int
+	for !c.stopped() {
 		len, err := binary.ReadUvarint(r)
 		if err != nil {
-			return err
+			return n, err
 		}
 
 		buf := make([]byte, len)
 		if _, err = io.ReadFull(r, buf); err != nil {
-			return err
+			if err == io.ErrUnexpectedEOF {
+				atomic.AddUint64(&c.dropped, 1)
+			}
+			return n, err
 		}
 
 		rec := &pb.LanzRecord{}
 		if err = proto.Unmarshal(buf, rec); err != nil {
-			return err
+			atomic.AddUint64(&c.dropped, 1)
+			return n, err
 		}
+		atomic.AddUint64(&c.parsed, 1)
 
-		ch <- rec
+		select {
+		case ch <- rec:
+			n++
+		case <-c.done:
+			atomic.AddUint64(&c.dropped, 1)
+		}
 	}
 
-	return nil
+	return n, nil
 }
 
-func (c *client) Stop() {
-	if c.stopping {
-		return
+// stopped returns whether Stop was called.
+func (c *client) stopped() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
 	}
+}
 
-	c.stopping = true
-	c.conn.Close()
+func (c *client) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *client) Stats() Stats {
+	return Stats{
+		Connects: atomic.LoadUint64(&c.connects),
+		Parsed:   atomic.LoadUint64(&c.parsed),
+		Dropped:  atomic.LoadUint64(&c.dropped),
+	}
 }
 
 type netConnector struct {
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,19 +30,25 @@ var testProtoBuf = &pb.LanzRecord{
 
 type testConnector struct {
 	reader   *bytes.Reader
-	open     bool
 	refusing bool
 	connect  chan bool
-	block    chan bool
+
+	mu    sync.Mutex
+	open  bool
+	block chan bool
 }
 
 func (c *testConnector) Read(p []byte) (int, error) {
+	c.mu.Lock()
 	if !c.open {
+		c.mu.Unlock()
 		return 0, errors.New("closed")
 	}
+	block := c.block
+	c.mu.Unlock()
 
 	if c.reader.Len() == 0 {
-		<-c.block
+		<-block
 		return 0, io.EOF
 	}
 
@@ -49,6 +56,8 @@ func (c *testConnector) Read(p []byte) (int, error) {
 }
 
 func (c *testConnector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if !c.open {
 		return nil
 	}
@@ -64,8 +73,10 @@ func (c *testConnector) Connect() error {
 	if c.refusing {
 		err = errors.New("refused")
 	} else {
+		c.mu.Lock()
 		c.block = make(chan bool)
 		c.open = true
+		c.mu.Unlock()
 	}
 	if c.connect != nil {
 		c.connect <- true
@@ -286,3 +297,71 @@ func TestDefaultConnector(t *testing.T) {
 		t.Fatalf("Expected protobuf %v, but got %v", testProtoBuf, p)
 	}
 }
+
+// This function tests that the backoff doubles after each failed connection, up to
+// the maximum backoff.
+func TestExponentialBackoff(t *testing.T) {
+	conn := &testConnector{refusing: true}
+	events := make(chan lanz.Event, 10)
+	ch := make(chan *pb.LanzRecord)
+	c := lanz.New(lanz.WithConnector(conn), lanz.WithBackoff(1*time.Millisecond),
+		lanz.WithMaxBackoff(4*time.Millisecond), lanz.WithEvents(events))
+	done := make(chan bool)
+	go func() {
+		c.Run(ch)
+		done <- true
+	}()
+
+	expected := []time.Duration{1, 2, 4, 4}
+	for i, d := range expected {
+		e := <-events
+		if e.State != lanz.Disconnected || e.Err == nil {
+			t.Fatalf("Event %d: expected a disconnection with an error, got %+v", i, e)
+		}
+		if e.Backoff != d*time.Millisecond {
+			t.Errorf("Event %d: expected backoff %s, got %s", i, d*time.Millisecond, e.Backoff)
+		}
+	}
+	c.Stop()
+	<-done
+}
+
+// This function tests the connection events and the counters of the client.
+func TestEventsAndStats(t *testing.T) {
+	stream := testStream()
+	uLen := binary.PutUvarint(stream, 3)
+	conn := &testConnector{
+		reader:  bytes.NewReader(append(testStream(), stream[:uLen+3]...)),
+		connect: make(chan bool),
+	}
+	events := make(chan lanz.Event, 10)
+	ch := make(chan *pb.LanzRecord)
+	c := lanz.New(lanz.WithConnector(conn), lanz.WithBackoff(1*time.Millisecond),
+		lanz.WithEvents(events))
+	done := make(chan bool)
+	go func() {
+		c.Run(ch)
+		done <- true
+	}()
+
+	<-conn.connect
+	if e := <-events; e.State != lanz.Connected {
+		t.Fatalf("Expected a connection event, got %+v", e)
+	}
+	if r := <-ch; !proto.Equal(r, testProtoBuf) {
+		t.Fatalf("Expected protobuf %v, but got %v", testProtoBuf, r)
+	}
+	// The malformed record makes the client reconnect
+	if e := <-events; e.State != lanz.Disconnected || e.Err == nil {
+		t.Fatalf("Expected a disconnection with an error, got %+v", e)
+	}
+	<-conn.connect
+	c.Stop()
+	<-done
+
+	stats := c.(lanz.StatsReporter).Stats()
+	expected := lanz.Stats{Connects: 2, Parsed: 1, Dropped: 1}
+	if stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, stats)
+	}
+}
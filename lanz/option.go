@@ -33,10 +33,28 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
-// WithBackoff specifies the backoff time after failed connection to LANZ server.
-// It only takes effect for default connector.
+// WithBackoff specifies the initial backoff time after failed connection to LANZ server.
+// It defaults to 30 seconds.
 func WithBackoff(d time.Duration) Option {
 	return func(c *client) {
 		c.backoff = d
 	}
 }
+
+// WithMaxBackoff specifies the maximum backoff time after consecutive failed
+// connections to LANZ server. It defaults to 30 seconds, or to the initial
+// backoff if that is longer.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(c *client) {
+		c.maxBackoff = d
+	}
+}
+
+// WithEvents specifies a channel on which the changes of the state of the
+// connection to LANZ server are sent. Events are dropped if the channel isn't
+// ready to receive them.
+func WithEvents(ch chan<- Event) Option {
+	return func(c *client) {
+		c.events = ch
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aristanetworks/glog"
+)
+
+// levels is the response of the handler.
+type levels struct {
+	// V is the global V level, the value of -v
+	V glog.Level `json:"v"`
+	// Subsystems are the V levels of the subsystems, nil for those
+	// that log at the level of -v
+	Subsystems map[string]*glog.Level `json:"subsystems"`
+}
+
+func currentLevels() levels {
+	lv := levels{V: Verbosity(), Subsystems: map[string]*glog.Level{}}
+	for _, l := range subsystems() {
+		if level, ok := l.Level(); ok {
+			lv.Subsystems[l.name] = &level
+		} else {
+			lv.Subsystems[l.name] = nil
+		}
+	}
+	return lv
+}
+
+// Handler returns an HTTP handler of the V levels. A GET returns the
+// levels as a JSON object, and a POST or PUT sets them from the form
+// values, v for the global level and the names of the subsystems for
+// theirs, an empty value making a subsystem log at the level of -v
+// again, e.g.
+//
+//	curl -X POST 'localhost:8080/debug/loglevel?v=1&collector=5'
+func Handler() http.Handler {
+	return http.HandlerFunc(handleLevels)
+}
+
+func handleLevels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := setForm(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(currentLevels())
+}
+
+// setForm sets the levels from the form values of r. Nothing is set if
+// a value is invalid.
+func setForm(r *http.Request) error {
+	var v *int
+	var settings []string
+	for name, values := range r.Form {
+		value := values[len(values)-1]
+		if name != "v" {
+			if strings.ContainsAny(value, ",=") {
+				return fmt.Errorf("invalid level for subsystem %q: %q", name, value)
+			}
+			settings = append(settings, name+"="+value)
+			continue
+		}
+		level, err := strconv.Atoi(value)
+		if err != nil || level < 0 {
+			return fmt.Errorf("invalid level for v: %q", value)
+		}
+		v = &level
+	}
+	if err := setLevels(strings.Join(settings, ",")); err != nil {
+		return err
+	}
+	if v != nil {
+		SetVerbosity(glog.Level(*v))
+	}
+	if len(r.Form) > 0 {
+		glog.Infof("V levels set over HTTP: %s", r.Form.Encode())
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// record is a log line in the json format.
+type record struct {
+	Time      string `json:"time"`
+	Severity  string `json:"severity,omitempty"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Subsystem string `json:"subsystem,omitempty"`
+	Msg       string `json:"msg"`
+}
+
+var severities = map[byte]string{
+	'I': "INFO",
+	'W': "WARNING",
+	'E': "ERROR",
+	'F': "FATAL",
+}
+
+// jsonWriter writes the lines logged by glog to w as JSON objects, a
+// line each. glog writes a line, with its header, in each Write.
+type jsonWriter struct {
+	w   io.Writer
+	now func() time.Time
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: w, now: time.Now}
+}
+
+func (w *jsonWriter) Write(p []byte) (int, error) {
+	r := parseLine(strings.TrimRight(string(p), "\n"))
+	r.Time = w.now().UTC().Format(time.RFC3339Nano)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Reset()
+	enc := json.NewEncoder(&w.buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		return 0, err
+	}
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parseLine parses a line logged by glog, in the format
+//
+//	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+//
+// where the message of a Logger starts with its subsystem in brackets.
+// The time of the header is left out, as it lacks the year and the time
+// zone. A line in another format is left as is in the message.
+func parseLine(line string) record {
+	const headerLen = len("I0102 15:04:05.000000 1234567 ")
+	r := record{Msg: line}
+	end := strings.Index(line, "] ")
+	if len(line) < headerLen || end < headerLen {
+		return r
+	}
+	severity, ok := severities[line[0]]
+	if !ok {
+		return r
+	}
+	fileLine := line[headerLen:end]
+	i := strings.LastIndexByte(fileLine, ':')
+	if i < 0 {
+		return r
+	}
+	n, err := strconv.Atoi(fileLine[i+1:])
+	if err != nil {
+		return r
+	}
+	r.Severity, r.File, r.Line, r.Msg = severity, fileLine[:i], n, line[end+2:]
+	if strings.HasPrefix(r.Msg, "[") {
+		if i := strings.Index(r.Msg, "] "); i > 0 && lookup(r.Msg[1:i]) != nil {
+			r.Subsystem, r.Msg = r.Msg[1:i], r.Msg[i+2:]
+		}
+	}
+	return r
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// Package logger provides the loggers of the subsystems of long-running
+// commands. They log with glog, prefixing their messages with the name
+// of their subsystem, but the verbosity of their V logs can be set per
+// subsystem, and changed while the command runs.
+//
+// Commands add these flags to those of glog with RegisterFlags:
+//
+//	-vsubsystem=""
+//		Comma-separated list of subsystem=N settings of the V level
+//		of the subsystems. The others log at the level of -v.
+//	-log_format=text
+//		Format of the log lines: text, the format of glog, or json,
+//		a JSON object per line.
+//
+// The verbosity is raised by one on SIGUSR1 and lowered by one on
+// SIGUSR2 once HandleSignals is called, and can be set over HTTP with
+// Handler.
+package logger
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aristanetworks/glog"
+)
+
+// Logger is the logger of a subsystem.
+type Logger struct {
+	name   string
+	prefix string
+	// level is the V level of the subsystem, or -1 if it logs at the
+	// level of -v. Accessed atomically.
+	level int32
+}
+
+var (
+	mu      sync.Mutex
+	loggers = map[string]*Logger{}
+
+	// vMu serializes the settings of -v, so that verbosity is the
+	// value set last in glog.
+	vMu sync.Mutex
+	// verbosity is the value of -v, once RegisterFlags wrapped it. glog
+	// doesn't read its level atomically, so it is kept here too.
+	// Accessed atomically.
+	verbosity int32
+)
+
+// RegisterFlags adds -vsubsystem and -log_format to fs. When fs has the
+// -v flag of glog, as flag.CommandLine does, the subsystems that don't
+// have their own level log at the level set with it. Commands call
+// RegisterFlags before parsing their flags.
+func RegisterFlags(fs *flag.FlagSet) {
+	if v := fs.Lookup("v"); v != nil {
+		if g, ok := v.Value.(flag.Getter); ok {
+			if level, ok := g.Get().(glog.Level); ok {
+				atomic.StoreInt32(&verbosity, int32(level))
+				v.Value = verbosityFlag{v.Value}
+			}
+		}
+	}
+	fs.Var(subsystemLevels{}, "vsubsystem",
+		"comma-separated list of subsystem=N settings for the V logs of subsystems")
+	fs.Var(&logFormat{format: "text"}, "log_format",
+		"format of the log lines: text or json")
+}
+
+// New returns the logger of the subsystem name, creating it if it
+// doesn't exist yet. Loggers should be created when their package is
+// initialized, so that their level can be set with -vsubsystem.
+func New(name string) *Logger {
+	if name == "" || name == "v" || strings.ContainsAny(name, "=,[] ") {
+		panic(fmt.Sprintf("invalid subsystem name %q", name))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if l, ok := loggers[name]; ok {
+		return l
+	}
+	l := &Logger{name: name, prefix: "[" + name + "] ", level: -1}
+	loggers[name] = l
+	return l
+}
+
+// lookup returns the logger of the subsystem name, or nil if it
+// doesn't exist.
+func lookup(name string) *Logger {
+	mu.Lock()
+	defer mu.Unlock()
+	return loggers[name]
+}
+
+// subsystems returns the loggers of all the subsystems, sorted by name.
+func subsystems() []*Logger {
+	mu.Lock()
+	ls := make([]*Logger, 0, len(loggers))
+	for _, l := range loggers {
+		ls = append(ls, l)
+	}
+	mu.Unlock()
+	sort.Slice(ls, func(i, j int) bool { return ls[i].name < ls[j].name })
+	return ls
+}
+
+// Name returns the name of the subsystem of l.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// Level returns the V level of the subsystem, and false if it logs at
+// the level of -v.
+func (l *Logger) Level() (glog.Level, bool) {
+	level := atomic.LoadInt32(&l.level)
+	if level < 0 {
+		return Verbosity(), false
+	}
+	return glog.Level(level), true
+}
+
+// SetLevel sets the V level of the subsystem.
+func (l *Logger) SetLevel(level glog.Level) {
+	if level < 0 {
+		level = 0
+	}
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// ResetLevel makes the subsystem log at the level of -v again.
+func (l *Logger) ResetLevel() {
+	atomic.StoreInt32(&l.level, -1)
+}
+
+// Verbosity returns the global V level, the value of -v.
+func Verbosity() glog.Level {
+	return glog.Level(atomic.LoadInt32(&verbosity))
+}
+
+// SetVerbosity sets the global V level, the value of -v.
+func SetVerbosity(level glog.Level) {
+	if level < 0 {
+		level = 0
+	}
+	vMu.Lock()
+	defer vMu.Unlock()
+	glog.SetVGlobal(strconv.Itoa(int(level)))
+	atomic.StoreInt32(&verbosity, int32(level))
+}
+
+// Verbose logs the V logs of a subsystem that are enabled. See V.
+type Verbose struct {
+	l *Logger
+}
+
+// V reports whether the V level of the subsystem is at least level,
+// like glog.V. The settings of -vmodule don't apply to subsystems.
+//
+//	if l.V(2).Enabled() { l.Info(expensiveDump()) }
+//	l.V(2).Infof("received %d responses", n)
+func (l *Logger) V(level glog.Level) Verbose {
+	if current, _ := l.Level(); current >= level {
+		return Verbose{l: l}
+	}
+	return Verbose{}
+}
+
+// Enabled returns whether the V log is enabled.
+func (v Verbose) Enabled() bool {
+	return v.l != nil
+}
+
+// Info is equivalent to Logger.Info, guarded by the value of v.
+func (v Verbose) Info(args ...interface{}) {
+	if v.l != nil {
+		glog.InfoDepth(1, v.l.prefix+fmt.Sprint(args...))
+	}
+}
+
+// Infof is equivalent to Logger.Infof, guarded by the value of v.
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v.l != nil {
+		glog.InfoDepth(1, v.l.prefix+fmt.Sprintf(format, args...))
+	}
+}
+
+// Info logs to the INFO log, in the manner of fmt.Print.
+func (l *Logger) Info(args ...interface{}) {
+	glog.InfoDepth(1, l.prefix+fmt.Sprint(args...))
+}
+
+// Infof logs to the INFO log, in the manner of fmt.Printf.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	glog.InfoDepth(1, l.prefix+fmt.Sprintf(format, args...))
+}
+
+// Error logs to the ERROR and INFO logs, in the manner of fmt.Print.
+func (l *Logger) Error(args ...interface{}) {
+	glog.ErrorDepth(1, l.prefix+fmt.Sprint(args...))
+}
+
+// Errorf logs to the ERROR and INFO logs, in the manner of fmt.Printf.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	glog.ErrorDepth(1, l.prefix+fmt.Sprintf(format, args...))
+}
+
+// Fatal logs to the FATAL, ERROR and INFO logs, in the manner of
+// fmt.Print, and exits.
+func (l *Logger) Fatal(args ...interface{}) {
+	glog.FatalDepth(1, l.prefix+fmt.Sprint(args...))
+}
+
+// Fatalf logs to the FATAL, ERROR and INFO logs, in the manner of
+// fmt.Printf, and exits.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	glog.FatalDepth(1, l.prefix+fmt.Sprintf(format, args...))
+}
+
+// setLevels sets the levels of the subsystems from a comma-separated
+// list of subsystem=N settings. An empty N resets the subsystem to the
+// level of -v.
+func setLevels(s string) error {
+	type setting struct {
+		l     *Logger
+		level int
+	}
+	var settings []setting
+	for _, kv := range strings.Split(s, ",") {
+		if kv == "" {
+			continue
+		}
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return fmt.Errorf("expected subsystem=N, got %q", kv)
+		}
+		l := lookup(kv[:i])
+		if l == nil {
+			return fmt.Errorf("unknown subsystem %q", kv[:i])
+		}
+		level := -1
+		if kv[i+1:] != "" {
+			var err error
+			if level, err = strconv.Atoi(kv[i+1:]); err != nil || level < 0 {
+				return fmt.Errorf("invalid level for subsystem %q: %q", kv[:i], kv[i+1:])
+			}
+		}
+		settings = append(settings, setting{l: l, level: level})
+	}
+	for _, s := range settings {
+		if s.level < 0 {
+			s.l.ResetLevel()
+		} else {
+			s.l.SetLevel(glog.Level(s.level))
+		}
+	}
+	return nil
+}
+
+// subsystemLevels is the flag.Value of -vsubsystem.
+type subsystemLevels struct{}
+
+func (subsystemLevels) String() string {
+	var settings []string
+	for _, l := range subsystems() {
+		if level, ok := l.Level(); ok {
+			settings = append(settings, fmt.Sprintf("%s=%d", l.name, level))
+		}
+	}
+	return strings.Join(settings, ",")
+}
+
+func (subsystemLevels) Set(s string) error {
+	return setLevels(s)
+}
+
+// verbosityFlag wraps the flag.Value of -v of glog, to keep verbosity
+// up to date when -v is set.
+type verbosityFlag struct {
+	flag.Value
+}
+
+func (f verbosityFlag) String() string {
+	return strconv.Itoa(int(Verbosity()))
+}
+
+func (f verbosityFlag) Get() interface{} {
+	return Verbosity()
+}
+
+func (f verbosityFlag) Set(s string) error {
+	level, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return err
+	}
+	vMu.Lock()
+	defer vMu.Unlock()
+	if err := f.Value.Set(s); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&verbosity, int32(level))
+	return nil
+}
+
+// logFormat is the flag.Value of -log_format.
+type logFormat struct {
+	format string
+}
+
+func (f *logFormat) String() string {
+	return f.format
+}
+
+func (f *logFormat) Set(s string) error {
+	switch s {
+	case "text":
+		glog.SetOutput(os.Stderr)
+	case "json":
+		glog.SetOutput(newJSONWriter(os.Stderr))
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", s)
+	}
+	f.format = s
+	return nil
+}
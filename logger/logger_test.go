@@ -0,0 +1,254 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/goarista/test"
+
+	"github.com/aristanetworks/glog"
+)
+
+var (
+	testLog  = New("test")
+	otherLog = New("other")
+)
+
+func init() {
+	RegisterFlags(flag.CommandLine)
+}
+
+// restoreLevels restores the levels changed by a test.
+func restoreLevels() {
+	SetVerbosity(0)
+	testLog.ResetLevel()
+	otherLog.ResetLevel()
+}
+
+func TestV(t *testing.T) {
+	defer restoreLevels()
+	if New("test") != testLog {
+		t.Error("New returned a new logger for an existing subsystem")
+	}
+	SetVerbosity(2)
+	if !testLog.V(2).Enabled() || testLog.V(3).Enabled() {
+		t.Error("expected the subsystem to log at the level of -v")
+	}
+	testLog.SetLevel(5)
+	if !testLog.V(5).Enabled() || testLog.V(6).Enabled() {
+		t.Error("expected the subsystem to log at level 5")
+	}
+	if otherLog.V(3).Enabled() {
+		t.Error("expected the other subsystem to log at the level of -v")
+	}
+	testLog.ResetLevel()
+	if level, ok := testLog.Level(); ok || level != 2 {
+		t.Errorf("expected the level of -v, got %d, %t", level, ok)
+	}
+	if err := flag.Set("v", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if !testLog.V(3).Enabled() || flag.Lookup("v").Value.String() != "3" {
+		t.Error("expected the subsystem to log at the level set with -v")
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	defer restoreLevels()
+	// A flag set with a -v flag other than the one of glog
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	v := fs.Int("v", 0, "")
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"-v=7", "-vsubsystem=test=4", "-log_format=text"}); err != nil {
+		t.Fatal(err)
+	}
+	if *v != 7 || Verbosity() != 0 {
+		t.Errorf("expected -v to be left alone, got %d and verbosity %d", *v, Verbosity())
+	}
+	if level, ok := testLog.Level(); !ok || level != 4 {
+		t.Errorf("expected level 4, got %d, %t", level, ok)
+	}
+	if err := fs.Parse([]string{"-log_format=xml"}); err == nil {
+		t.Error("expected an error for an unknown log format")
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	defer restoreLevels()
+	otherLog.SetLevel(1)
+	for s, expected := range map[string]string{
+		"":                 "other=1",
+		"test=3":           "other=1,test=3",
+		"test=3,other=":    "test=3",
+		"other=7,test=0,":  "other=7,test=0",
+		"test":             "error",
+		"unknown=1":        "error",
+		"test=-1":          "error",
+		"test=2,other=one": "error",
+	} {
+		t.Run(s, func(t *testing.T) {
+			var levels subsystemLevels
+			prev := levels.String()
+			err := levels.Set(s)
+			if expected == "error" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if levels.String() != prev {
+					t.Errorf("levels changed to %q after an error", levels.String())
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if levels.String() != expected {
+				t.Errorf("expected %q, got %q", expected, levels.String())
+			}
+			otherLog.SetLevel(1)
+			testLog.ResetLevel()
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	defer restoreLevels()
+	h := Handler()
+	one, five := glog.Level(1), glog.Level(5)
+	for name, tc := range map[string]struct {
+		method string
+		query  string
+		code   int
+		levels levels
+	}{
+		"get": {
+			method: http.MethodGet,
+			code:   http.StatusOK,
+			levels: levels{Subsystems: map[string]*glog.Level{"other": nil, "test": nil}},
+		},
+		"set": {
+			method: http.MethodPost,
+			query:  "v=1&test=5",
+			code:   http.StatusOK,
+			levels: levels{V: 1, Subsystems: map[string]*glog.Level{"other": nil, "test": &five}},
+		},
+		"reset": {
+			method: http.MethodPut,
+			query:  "test=&other=1",
+			code:   http.StatusOK,
+			levels: levels{V: 1, Subsystems: map[string]*glog.Level{"other": &one, "test": nil}},
+		},
+		"invalid v": {
+			method: http.MethodPost,
+			query:  "v=high&test=5",
+			code:   http.StatusBadRequest,
+		},
+		"unknown subsystem": {
+			method: http.MethodPost,
+			query:  "v=3&unknown=5",
+			code:   http.StatusBadRequest,
+		},
+		"method": {
+			method: http.MethodDelete,
+			code:   http.StatusMethodNotAllowed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			restoreLevels()
+			if name == "reset" {
+				SetVerbosity(1)
+				testLog.SetLevel(3)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, "/debug/loglevel?"+tc.query, nil))
+			if w.Code != tc.code {
+				t.Fatalf("expected status %d, got %d: %s", tc.code, w.Code, w.Body)
+			}
+			if tc.code != http.StatusOK {
+				if Verbosity() != 0 {
+					t.Errorf("V level set to %d after an error", Verbosity())
+				}
+				return
+			}
+			var lv levels
+			if err := json.Unmarshal(w.Body.Bytes(), &lv); err != nil {
+				t.Fatal(err)
+			}
+			if d := test.Diff(tc.levels, lv); d != "" {
+				t.Errorf("unexpected levels: %s", d)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	for line, expected := range map[string]record{
+		"I1014 12:34:56.789012      42 main.go:394] received SIGHUP": {
+			Severity: "INFO", File: "main.go", Line: 394, Msg: "received SIGHUP",
+		},
+		"E1014 12:34:56.789012 1234567 spool.go:7] [test] spool is full: [1 2] ": {
+			Severity: "ERROR", File: "spool.go", Line: 7, Subsystem: "test",
+			Msg: "spool is full: [1 2] ",
+		},
+		"W1014 12:34:56.789012      42 a.go:1] [unknown] not a subsystem": {
+			Severity: "WARNING", File: "a.go", Line: 1, Msg: "[unknown] not a subsystem",
+		},
+		"ERROR: logging before flag.Parse: ": {
+			Msg: "ERROR: logging before flag.Parse: ",
+		},
+		"X1014 12:34:56.789012      42 a.go:1] unknown severity": {
+			Msg: "X1014 12:34:56.789012      42 a.go:1] unknown severity",
+		},
+	} {
+		if d := test.Diff(expected, parseLine(line)); d != "" {
+			t.Errorf("unexpected record for %q: %s", line, d)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	defer restoreLevels()
+	var buf bytes.Buffer
+	w := newJSONWriter(&buf)
+	w.now = func() time.Time { return time.Unix(1, 500).In(time.FixedZone("PDT", -7*3600)) }
+	prev := glog.SetOutput(w)
+	defer glog.SetOutput(prev)
+
+	testLog.Infof("connected to %s", "collector")
+	testLog.V(1).Info("not logged")
+	testLog.SetLevel(1)
+	testLog.V(1).Info("logged <", 1, ">")
+	glog.Error("from glog")
+
+	var records []record
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var r record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("malformed line %q: %s", line, err)
+		}
+		r.Line = 0
+		records = append(records, r)
+	}
+	expected := []record{{
+		Time: "1970-01-01T00:00:01.0000005Z", Severity: "INFO", File: "logger_test.go",
+		Subsystem: "test", Msg: "connected to collector",
+	}, {
+		Time: "1970-01-01T00:00:01.0000005Z", Severity: "INFO", File: "logger_test.go",
+		Subsystem: "test", Msg: "logged <1>",
+	}, {
+		Time: "1970-01-01T00:00:01.0000005Z", Severity: "ERROR", File: "logger_test.go",
+		Msg: "from glog",
+	}}
+	if d := test.Diff(expected, records); d != "" {
+		t.Errorf("unexpected output: %s", d)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/aristanetworks/glog"
+)
+
+var handleSignals sync.Once
+
+// HandleSignals raises the global V level by one on each SIGUSR1, and
+// lowers it by one on each SIGUSR2, for the lifetime of the program.
+func HandleSignals() {
+	handleSignals.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+		go func() {
+			for sig := range c {
+				level := Verbosity()
+				if sig == syscall.SIGUSR1 {
+					level++
+				} else if level > 0 {
+					level--
+				}
+				SetVerbosity(level)
+				glog.Infof("received %s, V level set to %d", sig, level)
+			}
+		}()
+	})
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// +build !windows
+
+package logger
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/glog"
+)
+
+func TestHandleSignals(t *testing.T) {
+	defer restoreLevels()
+	HandleSignals()
+	for _, tc := range []struct {
+		sig   syscall.Signal
+		level glog.Level
+	}{
+		{syscall.SIGUSR1, 1},
+		{syscall.SIGUSR1, 2},
+		{syscall.SIGUSR2, 1},
+		{syscall.SIGUSR2, 0},
+	} {
+		if err := syscall.Kill(syscall.Getpid(), tc.sig); err != nil {
+			t.Fatal(err)
+		}
+		// The level is set asynchronously
+		deadline := time.Now().Add(5 * time.Second)
+		for Verbosity() != tc.level && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if Verbosity() != tc.level {
+			t.Fatalf("expected V level %d after %s, got %d", tc.level, tc.sig, Verbosity())
+		}
+	}
+}
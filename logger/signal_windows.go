@@ -0,0 +1,9 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package logger
+
+// HandleSignals does nothing, as there is no SIGUSR1 or SIGUSR2 on
+// Windows.
+func HandleSignals() {}
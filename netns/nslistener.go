@@ -9,12 +9,9 @@ import (
 	"errors"
 	"io"
 	"net"
-	"os"
-	"path/filepath"
 	"strings"
-	"time"
+	"sync"
 
-	"github.com/aristanetworks/fsnotify"
 	"github.com/aristanetworks/glog"
 	"github.com/aristanetworks/goarista/dscp"
 )
@@ -29,41 +26,36 @@ var makeListener = func(nsName string, addr *net.TCPAddr, tos byte) (net.Listene
 	return listener, err
 }
 
-func accept(listener net.Listener, conns chan<- net.Conn) {
+func accept(listener net.Listener, conns chan<- net.Conn, done <-chan struct{}) {
 	for {
 		c, err := listener.Accept()
 		if err != nil {
 			glog.Infof("Accept error: %v", err)
 			return
 		}
-		conns <- c
-	}
-}
-
-func waitForMount(mountPoint string) bool {
-	for !hasMount(mountPoint) {
-		time.Sleep(time.Second)
-		if _, err := os.Stat(mountPoint); err != nil {
-			glog.Infof("error stating %s: %v", mountPoint, err)
-			return false
+		select {
+		case conns <- c:
+		case <-done:
+			c.Close()
+			return
 		}
 	}
-
-	return true
 }
 
 // nsListener is a net.Listener that binds to a specific network namespace when it becomes available
 // and in case it gets deleted and recreated it will automatically bind to the newly created
-// namespace.
+// namespace. The namespace is watched with a Watcher.
 type nsListener struct {
-	listener net.Listener
-	watcher  *fsnotify.Watcher
-	nsName   string
-	nsFile   string
-	addr     *net.TCPAddr
-	tos      byte
-	done     chan struct{}
-	conns    chan net.Conn
+	// listener is only accessed by the callback of watcher, and by
+	// Close once watcher is closed.
+	listener  net.Listener
+	watcher   *Watcher
+	nsName    string
+	addr      *net.TCPAddr
+	tos       byte
+	closeOnce sync.Once
+	done      chan struct{}
+	conns     chan net.Conn
 }
 
 func (l *nsListener) tearDown() {
@@ -74,100 +66,69 @@ func (l *nsListener) tearDown() {
 	}
 }
 
-func (l *nsListener) setUp() bool {
+func (l *nsListener) setUp() {
 	glog.Infof("Creating listener in namespace %v", l.nsName)
-	if err := l.watcher.Add(l.nsFile); err != nil {
-		glog.Infof("Can't watch the file (will try again): %v", err)
-		return false
-	}
 	listener, err := makeListener(l.nsName, l.addr, l.tos)
 	if err != nil {
-		glog.Infof("Can't create TCP listener (will try again): %v", err)
-		return false
+		glog.Infof("Can't create TCP listener (will try again when the namespace is "+
+			"recreated): %v", err)
+		return
 	}
 	l.listener = listener
-	go accept(l.listener, l.conns)
-
-	return true
+	go accept(l.listener, l.conns, l.done)
 }
 
-func (l *nsListener) watch() {
-	var mounted bool
-	if hasMount(l.nsFile) {
-		mounted = l.setUp()
-	}
-
-	for {
-		select {
-		case <-l.done:
-			l.tearDown()
-			go func() {
-				// Drain the events, otherwise closing the watcher will get stuck
-				for range l.watcher.Events {
-				}
-			}()
-			l.watcher.Close()
-			close(l.conns)
-			return
-		case ev := <-l.watcher.Events:
-			if ev.Name != l.nsFile {
-				continue
-			}
-			if ev.Op&fsnotify.Create == fsnotify.Create {
-				if mounted || !waitForMount(l.nsFile) {
-					continue
-				}
-				mounted = l.setUp()
-			}
-			if ev.Op&fsnotify.Remove == fsnotify.Remove {
-				l.tearDown()
-				mounted = false
-			}
-		}
+// namespaceChanged is the WatchCallback of the watcher of l.
+func (l *nsListener) namespaceChanged(_ string, exists bool) {
+	if exists {
+		l.setUp()
+	} else {
+		l.tearDown()
 	}
 }
 
-func (l *nsListener) setupWatch() error {
-	w, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	if err = w.Add(filepath.Dir(l.nsFile)); err != nil {
-		return err
-	}
-
-	l.watcher = w
-	go l.watch()
-	return nil
-}
-
-func newNSListenerWithDir(nsDir, nsName string, addr *net.TCPAddr, tos byte) (net.Listener, error) {
+// newNSListenerWithWatcher returns an nsListener watching its
+// namespace with the Watcher returned by newWatcher.
+func newNSListenerWithWatcher(nsName string, addr *net.TCPAddr, tos byte,
+	newWatcher func(WatchCallback) (*Watcher, error)) (net.Listener, error) {
 	l := &nsListener{
 		nsName: nsName,
-		nsFile: filepath.Join(nsDir, nsName),
 		addr:   addr,
 		tos:    tos,
 		done:   make(chan struct{}),
 		conns:  make(chan net.Conn),
 	}
-	if err := l.setupWatch(); err != nil {
+	w, err := newWatcher(l.namespaceChanged)
+	if err != nil {
 		return nil, err
 	}
-
+	l.watcher = w
 	return l, nil
 }
 
+func newNSListenerWithDir(nsDir, nsName string, addr *net.TCPAddr, tos byte) (net.Listener, error) {
+	return newNSListenerWithWatcher(nsName, addr, tos, func(cb WatchCallback) (*Watcher, error) {
+		return newWatcherWithDir(nsDir, nsName, cb, nil, nil)
+	})
+}
+
 // Accept accepts a connection on the listener socket.
 func (l *nsListener) Accept() (net.Conn, error) {
-	if c, ok := <-l.conns; ok {
+	select {
+	case c := <-l.conns:
 		return c, nil
+	case <-l.done:
+		return nil, errors.New("listener closed")
 	}
-	return nil, errors.New("listener closed")
 }
 
 // Close closes the listener.
 func (l *nsListener) Close() error {
-	close(l.done)
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.watcher.Close()
+		l.tearDown()
+	})
 	return nil
 }
 
@@ -40,10 +40,7 @@ func NewNSListener(nsName string, addr *net.TCPAddr, tos byte) (net.Listener, er
 	if nsName == "" || nsName == "default" {
 		return makeListener(nsName, addr, tos)
 	}
-	nsDir, err := getNsDir()
-	if err != nil {
-		return nil, err
-	}
-
-	return newNSListenerWithDir(nsDir, nsName, addr, tos)
+	return newNSListenerWithWatcher(nsName, addr, tos, func(cb WatchCallback) (*Watcher, error) {
+		return NewWatcher(nsName, cb)
+	})
 }
@@ -65,8 +65,10 @@ func makeMockListener(n int) func(string, *net.TCPAddr, byte) (net.Listener, err
 
 func TestNSListener(t *testing.T) {
 	makeListener = makeMockListener(1)
-	hasMount = func(_ string) bool {
-		return true
+	// The namespace is mounted while its file exists
+	hasMount = func(mountPoint string) bool {
+		_, err := os.Stat(mountPoint)
+		return err == nil
 	}
 
 	nsDir, err := ioutil.TempDir("", "netns")
@@ -145,7 +147,16 @@ func TestNSListenerClose(t *testing.T) {
 		l.Accept()
 		close(done)
 	}()
-	l.Close()
+	// Concurrent closes must not close the listener twice
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Close()
+		}()
+	}
+	wg.Wait()
 	<-done
 }
 
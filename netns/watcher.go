@@ -0,0 +1,249 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package netns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aristanetworks/fsnotify"
+	"github.com/aristanetworks/glog"
+)
+
+// mountPollInterval is how often a watcher checks whether a namespace
+// file that was created has been mounted.
+const mountPollInterval = time.Second
+
+// WatchCallback is a function that gets called by a Watcher when the
+// network namespace nsName appears, with exists set to true, or
+// disappears, with exists set to false.
+type WatchCallback func(nsName string, exists bool)
+
+// Watcher watches a network namespace and calls its callback whenever
+// that namespace is created or deleted. The callback is called from the
+// goroutine of the watcher, one call at a time, so it should not block.
+type Watcher struct {
+	nsName  string
+	nsFile  string
+	cb      WatchCallback
+	watcher *fsnotify.Watcher
+	// nudge is signaled when the namespaces may have changed without
+	// an event from watcher, e.g. when the kernel notifies of a new
+	// namespace ID.
+	nudge     <-chan struct{}
+	stopNudge func()
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+func newWatcherWithDir(nsDir, nsName string, cb WatchCallback, nudge <-chan struct{},
+	stopNudge func()) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(nsDir); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	w := &Watcher{
+		nsName:    nsName,
+		nsFile:    filepath.Join(nsDir, nsName),
+		cb:        cb,
+		watcher:   fw,
+		nudge:     nudge,
+		stopNudge: stopNudge,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go w.watch()
+	return w, nil
+}
+
+func (w *Watcher) watch() {
+	defer close(w.stopped)
+	var exists bool
+	// poll is set while the namespace file exists but isn't mounted yet
+	var poll <-chan time.Time
+	check := func() {
+		poll = nil
+		if mounted := hasMount(w.nsFile); mounted != exists {
+			exists = mounted
+			w.cb(w.nsName, exists)
+		}
+		if !exists {
+			if _, err := os.Stat(w.nsFile); err == nil {
+				poll = time.After(mountPollInterval)
+			}
+		}
+	}
+	check()
+
+	for {
+		select {
+		case <-w.done:
+			go func() {
+				// Drain the events, otherwise closing the watcher will get stuck
+				for range w.watcher.Events {
+				}
+			}()
+			w.watcher.Close()
+			if w.stopNudge != nil {
+				w.stopNudge()
+			}
+			return
+		case ev := <-w.watcher.Events:
+			if ev.Name == w.nsFile && ev.Op&(fsnotify.Create|fsnotify.Remove) != 0 {
+				check()
+			}
+		case err := <-w.watcher.Errors:
+			glog.Infof("Error watching namespace %s: %v", w.nsName, err)
+		case <-w.nudge:
+			check()
+		case <-poll:
+			check()
+		}
+	}
+}
+
+// Close stops the watcher. The callback is not called anymore once
+// Close returns.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	<-w.stopped
+	return nil
+}
+
+// Waiter waits for a network namespace to be created. It watches the
+// namespace with a single Watcher for all its waits, so that it can be
+// kept by code that repeatedly needs the namespace, such as a dialer.
+type Waiter struct {
+	nsName    string
+	watcher   *Watcher
+	closeOnce sync.Once
+	done      chan struct{}
+
+	mu sync.Mutex
+	// exists is closed while the namespace exists
+	exists chan struct{}
+}
+
+// NewWaiter returns a Waiter for the network namespace nsName.
+func NewWaiter(nsName string) (*Waiter, error) {
+	return newWaiterWithWatcher(nsName, func(cb WatchCallback) (*Watcher, error) {
+		return NewWatcher(nsName, cb)
+	})
+}
+
+// newWaiterWithWatcher returns a Waiter watching its namespace with
+// the Watcher returned by newWatcher.
+func newWaiterWithWatcher(nsName string,
+	newWatcher func(WatchCallback) (*Watcher, error)) (*Waiter, error) {
+	w := &Waiter{
+		nsName: nsName,
+		done:   make(chan struct{}),
+		exists: make(chan struct{}),
+	}
+	// The default namespace always exists
+	if nsName == "" || nsName == "default" {
+		close(w.exists)
+		return w, nil
+	}
+	watcher, err := newWatcher(w.namespaceChanged)
+	if err != nil {
+		return nil, err
+	}
+	w.watcher = watcher
+	return w, nil
+}
+
+// namespaceChanged is the WatchCallback of the watcher of w.
+func (w *Waiter) namespaceChanged(_ string, exists bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if exists {
+		close(w.exists)
+	} else {
+		w.exists = make(chan struct{})
+	}
+}
+
+// Wait returns once the network namespace exists, or with an error
+// when ctx is done or w is closed.
+func (w *Waiter) Wait(ctx context.Context) error {
+	// select picks any of the ready cases, so w being closed is checked
+	// first, and again once the namespace exists
+	if w.closed() {
+		return errWaiterClosed
+	}
+	w.mu.Lock()
+	exists := w.exists
+	w.mu.Unlock()
+	select {
+	case <-exists:
+		if w.closed() {
+			return errWaiterClosed
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return errWaiterClosed
+	}
+}
+
+var errWaiterClosed = errors.New("waiter closed")
+
+// closed returns whether Close was called.
+func (w *Waiter) closed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// DoEventually waits for the network namespace like Wait, then calls
+// cb in it like Do.
+func (w *Waiter) DoEventually(ctx context.Context, cb Callback) error {
+	if err := w.Wait(ctx); err != nil {
+		return err
+	}
+	return Do(w.nsName, cb)
+}
+
+// Close stops watching the network namespace. The pending waits
+// return an error.
+func (w *Waiter) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	if w.watcher != nil {
+		return w.watcher.Close()
+	}
+	return nil
+}
+
+// DoEventually is like Do, except that if the network namespace nsName
+// doesn't exist yet, it waits for it to be created before calling cb.
+// This allows programs to start before the VRF they use is configured.
+func DoEventually(nsName string, cb Callback) error {
+	return DoEventuallyContext(context.Background(), nsName, cb)
+}
+
+// DoEventuallyContext is like DoEventually, but stops waiting for the
+// network namespace and returns the error of ctx when ctx is done.
+func DoEventuallyContext(ctx context.Context, nsName string, cb Callback) error {
+	w, err := NewWaiter(nsName)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return w.DoEventually(ctx, cb)
+}
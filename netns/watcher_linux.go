@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package netns
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/aristanetworks/glog"
+	"golang.org/x/sys/unix"
+)
+
+// rtnlgrpNSID is the rtnetlink multicast group of the notifications of
+// network namespace IDs, RTNLGRP_NSID in linux/rtnetlink.h.
+const rtnlgrpNSID = 28
+
+// NewWatcher returns a Watcher calling cb when the network namespace
+// nsName is created or deleted. If the namespace already exists, cb is
+// called right away. The namespace directory is watched with inotify,
+// and the network namespace ID notifications of rtnetlink, when
+// available, make the watcher check the namespace again.
+func NewWatcher(nsName string, cb WatchCallback) (*Watcher, error) {
+	nsDir, err := getNsDir()
+	if err != nil {
+		return nil, err
+	}
+	nudge, stopNudge, err := subscribeNSIDs()
+	if err != nil {
+		// inotify is enough to notice the namespace
+		glog.V(1).Infof("Can't subscribe to namespace ID notifications: %v", err)
+	}
+	w, err := newWatcherWithDir(nsDir, nsName, cb, nudge, stopNudge)
+	if err != nil && stopNudge != nil {
+		stopNudge()
+	}
+	return w, err
+}
+
+// subscribeNSIDs returns a channel signaled whenever the kernel
+// notifies of a new or deleted network namespace ID, and a function
+// to stop the notifications.
+func subscribeNSIDs() (<-chan struct{}, func(), error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK,
+		unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, nil, err
+	}
+	sa := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1 << (rtnlgrpNSID - 1)}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, nil, err
+	}
+	// The file is non-blocking, so closing it interrupts a pending Read
+	f := os.NewFile(uintptr(fd), "netlink")
+	nudge := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, os.Getpagesize())
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				if m.Header.Type != unix.RTM_NEWNSID && m.Header.Type != unix.RTM_DELNSID {
+					continue
+				}
+				select {
+				case nudge <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return nudge, func() { f.Close() }, nil
+}
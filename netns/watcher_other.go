@@ -0,0 +1,25 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+// +build !linux
+
+package netns
+
+// NewWatcher returns a Watcher calling cb when the network namespace
+// nsName is created or deleted. Without network namespaces, cb is
+// called once right away as if the namespace existed.
+func NewWatcher(nsName string, cb WatchCallback) (*Watcher, error) {
+	w := &Watcher{
+		nsName:  nsName,
+		cb:      cb,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.stopped)
+		cb(nsName, true)
+		<-w.done
+	}()
+	return w, nil
+}
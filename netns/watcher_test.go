@@ -0,0 +1,196 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package netns
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type watchEvent struct {
+	nsName string
+	exists bool
+}
+
+func expectEvent(t *testing.T, events <-chan watchEvent, expected watchEvent) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		if ev != expected {
+			t.Fatalf("Expected event %+v, but got %+v", expected, ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for event %+v", expected)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	hasMount = func(mountPoint string) bool {
+		_, err := os.Stat(mountPoint)
+		return err == nil
+	}
+
+	nsDir, err := ioutil.TempDir("", "netns")
+	if err != nil {
+		t.Fatalf("Can't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(nsDir)
+	nsFile := filepath.Join(nsDir, "ns-yolo")
+	if err = ioutil.WriteFile(nsFile, []byte{}, os.FileMode(0777)); err != nil {
+		t.Fatalf("Can't create ns file: %v", err)
+	}
+
+	events := make(chan watchEvent, 10)
+	w, err := newWatcherWithDir(nsDir, "ns-yolo", func(nsName string, exists bool) {
+		events <- watchEvent{nsName: nsName, exists: exists}
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create watcher: %v", err)
+	}
+	// The namespace already exists
+	expectEvent(t, events, watchEvent{nsName: "ns-yolo", exists: true})
+
+	for i := 0; i < 3; i++ {
+		if err = os.Remove(nsFile); err != nil {
+			t.Fatalf("Can't remove ns file: %v", err)
+		}
+		expectEvent(t, events, watchEvent{nsName: "ns-yolo", exists: false})
+		if err = ioutil.WriteFile(nsFile, []byte{}, os.FileMode(0777)); err != nil {
+			t.Fatalf("Can't create ns file: %v", err)
+		}
+		expectEvent(t, events, watchEvent{nsName: "ns-yolo", exists: true})
+	}
+
+	// Namespaces other than the watched one are ignored
+	if err = ioutil.WriteFile(filepath.Join(nsDir, "ns-other"), []byte{},
+		os.FileMode(0777)); err != nil {
+		t.Fatalf("Can't create ns file: %v", err)
+	}
+	w.Close()
+	select {
+	case ev := <-events:
+		t.Fatalf("Unexpected event %+v", ev)
+	default:
+	}
+}
+
+func TestWatcherNudge(t *testing.T) {
+	// The namespace is mounted without any event on its directory
+	var mounted int32
+	hasMount = func(_ string) bool {
+		return atomic.LoadInt32(&mounted) == 1
+	}
+
+	nsDir, err := ioutil.TempDir("", "netns")
+	if err != nil {
+		t.Fatalf("Can't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(nsDir)
+
+	events := make(chan watchEvent, 10)
+	nudge := make(chan struct{})
+	var stopped int32
+	w, err := newWatcherWithDir(nsDir, "ns-yolo", func(nsName string, exists bool) {
+		events <- watchEvent{nsName: nsName, exists: exists}
+	}, nudge, func() { atomic.StoreInt32(&stopped, 1) })
+	if err != nil {
+		t.Fatalf("Can't create watcher: %v", err)
+	}
+
+	atomic.StoreInt32(&mounted, 1)
+	nudge <- struct{}{}
+	expectEvent(t, events, watchEvent{nsName: "ns-yolo", exists: true})
+	atomic.StoreInt32(&mounted, 0)
+	nudge <- struct{}{}
+	expectEvent(t, events, watchEvent{nsName: "ns-yolo", exists: false})
+
+	w.Close()
+	if atomic.LoadInt32(&stopped) != 1 {
+		t.Fatalf("Nudges not stopped after closing the watcher")
+	}
+}
+
+func TestWaiter(t *testing.T) {
+	var mounted int32
+	hasMount = func(_ string) bool {
+		return atomic.LoadInt32(&mounted) == 1
+	}
+
+	nsDir, err := ioutil.TempDir("", "netns")
+	if err != nil {
+		t.Fatalf("Can't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(nsDir)
+
+	nudge := make(chan struct{})
+	w, err := newWaiterWithWatcher("ns-yolo", func(cb WatchCallback) (*Watcher, error) {
+		return newWatcherWithDir(nsDir, "ns-yolo", cb, nudge, nil)
+	})
+	if err != nil {
+		t.Fatalf("Can't create waiter: %v", err)
+	}
+	defer w.Close()
+
+	// The namespace doesn't exist yet
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	// The same watcher notices the namespace for all the waits
+	for i := 0; i < 3; i++ {
+		atomic.StoreInt32(&mounted, 1)
+		nudge <- struct{}{}
+		if err := w.Wait(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		atomic.StoreInt32(&mounted, 0)
+		nudge <- struct{}{}
+	}
+	// Wait for the watcher to notice that the namespace is gone
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		err := w.Wait(ctx)
+		cancel()
+		if err == context.DeadlineExceeded {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- w.Wait(context.Background())
+	}()
+	w.Close()
+	if err := <-done; err == nil {
+		t.Fatalf("Expected an error from a wait on a closed waiter")
+	}
+	// Later waits fail right away
+	if err := w.Wait(context.Background()); err == nil {
+		t.Fatalf("Expected an error from a wait on a closed waiter")
+	}
+}
+
+func TestDoEventuallyDefault(t *testing.T) {
+	var called bool
+	if err := DoEventually("", func() error {
+		called = true
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !called {
+		t.Fatalf("Callback not called")
+	}
+}